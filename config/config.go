@@ -1,9 +1,23 @@
 package config
 
-// Security secrets
+import (
+	"os"
+	"strconv"
+)
+
+// Legacy secret. Tokens are signed with the key ring (see SigningKey); this
+// only verifies login links issued before it existed, and never signs anything.
 const (
-	JWT_SECRET     = "your_super_secret_key_here"
-	SESSION_SECRET = "your_super_secret_key_here"
+	JWT_SECRET = "your_super_secret_key_here"
+)
+
+// JWT signing key ring
+const (
+	JWT_SIGNING_ALG            = "HS256"                // algorithm for new keys: HS256, RS256 or EdDSA
+	SIGNING_KEY_RETENTION_DAYS = 30                     // how long a rotated-out key still verifies tokens
+	SIGNING_KEY_CACHE_SECONDS  = 60                     // how often each instance reloads the key ring
+	ACCEPT_LEGACY_TOKENS       = true                   // verify login links without a kid using JWT_SECRET
+	LEGACY_TOKEN_CUTOFF        = "2027-04-17T00:00:00Z" // kid-less login links stop working after this; reissue them before
 )
 
 // URLs
@@ -12,7 +26,192 @@ const (
 	BACKEND_URL  = "http://localhost:8080"
 )
 
-// Token expiration
+// Login-link token expiration
+const (
+	LOGIN_TOKEN_DEFAULT_EXPIRY_DAYS = 30
+	LOGIN_TOKEN_MAX_EXPIRY_DAYS     = 365 * 5
+)
+
+// Session lifetimes
+const (
+	ACCESS_TOKEN_EXPIRY_MINUTES      = 15
+	REFRESH_TOKEN_EXPIRY_DAYS        = 30
+	SESSION_LAST_SEEN_UPDATE_SECONDS = 60 // minimum interval between last-seen writes
+)
+
+// Two-factor authentication
+const (
+	TOTP_ISSUER                  = "VMS"
+	MFA_CHALLENGE_EXPIRY_MINUTES = 5
+	RECOVERY_CODE_COUNT          = 10
+)
+
+// Login throttling. Each failure past the backoff threshold doubles the wait
+// (starting at LOGIN_BACKOFF_BASE_SECONDS); reaching the lockout threshold
+// blocks the key for LOGIN_LOCKOUT_MINUTES.
+const (
+	LOGIN_USER_BACKOFF_AFTER     = 3
+	LOGIN_USER_LOCKOUT_AFTER     = 10
+	LOGIN_IP_BACKOFF_AFTER       = 10
+	LOGIN_IP_LOCKOUT_AFTER       = 50
+	LOGIN_BACKOFF_BASE_SECONDS   = 1
+	LOGIN_LOCKOUT_MINUTES        = 15
+	LOGIN_FAILURE_WINDOW_MINUTES = 60
+)
+
+// Only trust X-Forwarded-For / X-Real-IP when running behind a known reverse proxy
+const (
+	TRUST_PROXY_HEADERS = false
+)
+
+// Password policy
 const (
-	TOKEN_EXPIRY_YEARS = 5
-)
\ No newline at end of file
+	PASSWORD_MIN_LENGTH                  = 12
+	PASSWORD_REQUIRE_UPPER               = true
+	PASSWORD_REQUIRE_LOWER               = true
+	PASSWORD_REQUIRE_DIGIT               = true
+	PASSWORD_REQUIRE_SYMBOL              = true
+	PASSWORD_HISTORY_COUNT               = 5  // previous passwords that can't be reused
+	PASSWORD_MAX_AGE_DAYS                = 90 // 0 disables forced rotation
+	PASSWORD_CHANGE_TOKEN_EXPIRY_MINUTES = 10
+	PASSWORD_RESET_EXPIRY_HOURS          = 24
+)
+
+// Password hashing. New and changed passwords use PASSWORD_HASH_ALG; hashes
+// in any other format or with older argon2id parameters are upgraded on the
+// next successful login.
+const (
+	PASSWORD_HASH_ALG  = "argon2id" // argon2id or bcrypt
+	ARGON2_MEMORY_KB   = 64 * 1024
+	ARGON2_ITERATIONS  = 3
+	ARGON2_PARALLELISM = 2
+	ARGON2_SALT_LENGTH = 16
+	ARGON2_KEY_LENGTH  = 32
+	BCRYPT_COST        = 12
+)
+
+// OpenID Connect single sign-on (authorization code + PKCE). These are
+// variables so a deployment can point them at its IdP through the
+// environment (OIDC_ENABLED, OIDC_ISSUER_URL, ...) without a rebuild.
+var (
+	OIDC_ENABLED          = envBool("OIDC_ENABLED", false)
+	OIDC_ISSUER_URL       = envString("OIDC_ISSUER_URL", "http://localhost:9000")
+	OIDC_CLIENT_ID        = envString("OIDC_CLIENT_ID", "vms-backend")
+	OIDC_CLIENT_SECRET    = envString("OIDC_CLIENT_SECRET", "")
+	OIDC_REDIRECT_URL     = envString("OIDC_REDIRECT_URL", BACKEND_URL+"/oidc/callback")
+	OIDC_SCOPES           = envString("OIDC_SCOPES", "openid profile email")
+	OIDC_USERNAME_CLAIM   = envString("OIDC_USERNAME_CLAIM", "preferred_username")
+	OIDC_ROLE_CLAIM       = envString("OIDC_ROLE_CLAIM", "vms_role")           // string or array of IdP roles/groups
+	OIDC_GROUP_ID_CLAIM   = envString("OIDC_GROUP_ID_CLAIM", "vms_group_id")   // number or numeric string
+	OIDC_AREA_NAME_CLAIM  = envString("OIDC_AREA_NAME_CLAIM", "vms_area_name") // string
+	OIDC_DEFAULT_ROLE     = envString("OIDC_DEFAULT_ROLE", "Basic User")
+	OIDC_DEFAULT_GROUP_ID = envInt("OIDC_DEFAULT_GROUP_ID", 0) // 0 rejects users without a group claim
+)
+
+const (
+	OIDC_STATE_EXPIRY_MINUTES = 10
+)
+
+// OIDC_ROLE_MAPPING maps IdP role/group claim values to VMS roles.
+// Claim values that already name a VMS role are used as-is.
+var OIDC_ROLE_MAPPING = map[string]string{
+	"vms-admins":      "admin",
+	"vms-area-admins": "Area Admin",
+	"vms-operators":   "Basic User",
+}
+
+// LDAP / Active Directory authentication. When enabled it is tried before
+// local passwords; local users keep working as a fallback. Like the OIDC
+// settings these can be set through the environment (LDAP_ENABLED, LDAP_URL, ...).
+var (
+	LDAP_ENABLED              = envBool("LDAP_ENABLED", false)
+	LDAP_URL                  = envString("LDAP_URL", "ldap://localhost:389") // ldaps:// for implicit TLS
+	LDAP_START_TLS            = envBool("LDAP_START_TLS", false)
+	LDAP_INSECURE_SKIP_VERIFY = envBool("LDAP_INSECURE_SKIP_VERIFY", false)
+	LDAP_BIND_DN              = envString("LDAP_BIND_DN", "CN=vms-service,OU=Service Accounts,DC=example,DC=com")
+	LDAP_BIND_PASSWORD        = envString("LDAP_BIND_PASSWORD", "")
+	LDAP_BASE_DN              = envString("LDAP_BASE_DN", "DC=example,DC=com")
+	LDAP_USER_OBJECT_CLASS    = envString("LDAP_USER_OBJECT_CLASS", "person")
+	LDAP_USERNAME_ATTRIBUTE   = envString("LDAP_USERNAME_ATTRIBUTE", "sAMAccountName")
+	LDAP_GROUP_ATTRIBUTE      = envString("LDAP_GROUP_ATTRIBUTE", "memberOf")
+	LDAP_GROUP_ID_ATTRIBUTE   = envString("LDAP_GROUP_ID_ATTRIBUTE", "")  // optional attribute holding the GroupId
+	LDAP_AREA_NAME_ATTRIBUTE  = envString("LDAP_AREA_NAME_ATTRIBUTE", "") // optional attribute holding the AreaName
+	LDAP_TIMEOUT_SECONDS      = envInt("LDAP_TIMEOUT_SECONDS", 5)
+)
+
+// LDAPArea is the GroupId/AreaName a directory group maps to
+type LDAPArea struct {
+	GroupId  int
+	AreaName string
+}
+
+// LDAP_ROLE_GROUPS maps directory group DNs to VMS roles (compared case-insensitively)
+var LDAP_ROLE_GROUPS = map[string]string{
+	"CN=VMS Admins,OU=Groups,DC=example,DC=com":      "admin",
+	"CN=VMS Area Admins,OU=Groups,DC=example,DC=com": "Area Admin",
+	"CN=VMS Guards,OU=Groups,DC=example,DC=com":      "Basic User",
+}
+
+// LDAP_AREA_GROUPS maps directory group DNs to areas, used when the
+// GroupId/AreaName attributes are not configured or not set on the user
+var LDAP_AREA_GROUPS = map[string]LDAPArea{
+	"CN=VMS Area North,OU=Groups,DC=example,DC=com": {GroupId: 1, AreaName: "North"},
+	"CN=VMS Area South,OU=Groups,DC=example,DC=com": {GroupId: 2, AreaName: "South"},
+}
+
+// API key settings. Keys are sent as "Authorization: Bearer vms_<prefix>_<secret>".
+const (
+	API_KEY_MAX_EXPIRY_DAYS          = 365 // upper bound for a requested expiry; omit expiry for a non-expiring key
+	API_KEY_LAST_USED_UPDATE_SECONDS = 60  // minimum interval between last-used writes
+)
+
+// API_KEY_SCOPES maps each API key scope to the route prefix it unlocks.
+// A ":read" scope allows GET requests, ":write" allows POST, PUT and DELETE.
+var API_KEY_SCOPES = map[string]string{
+	"view-groups":      "/view-groups",
+	"custom-maps":      "/custom-maps",
+	"user-preferences": "/user-preferences",
+}
+
+// Video-wall / kiosk devices. Devices send "Authorization: Bearer vmsdev_<prefix>_<secret>".
+const (
+	DEVICE_HEARTBEAT_SECONDS     = 60  // interval devices are told to use for /device/heartbeat
+	DEVICE_OFFLINE_AFTER_SECONDS = 180 // a device not seen for this long is reported offline
+)
+
+// Admin impersonation ("view as user")
+const (
+	IMPERSONATION_EXPIRY_MINUTES = 60
+)
+
+// Roles and permissions. Roles live in the database; each instance caches them
+// for this long, so role edits made elsewhere take effect within the interval.
+const (
+	ROLE_CACHE_SECONDS = 30
+)
+
+// envString returns the environment variable name, or fallback when it is unset
+func envString(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// envBool returns the environment variable name parsed as a bool, or fallback
+// when it is unset or not a bool
+func envBool(name string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// envInt returns the environment variable name parsed as an int, or fallback
+// when it is unset or not a number
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}
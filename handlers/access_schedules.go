@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+// scheduleErrorStatus maps access schedule store errors to HTTP statuses
+var scheduleErrorStatus = map[error]int{
+	utils.ErrScheduleNotFound:  http.StatusNotFound,
+	utils.ErrScheduleNameTaken: http.StatusConflict,
+}
+
+// sendScheduleError responds with the status for an access schedule store error
+func sendScheduleError(w http.ResponseWriter, err error, fallback string) {
+	for scheduleErr, status := range scheduleErrorStatus {
+		if errors.Is(err, scheduleErr) {
+			utils.SendError(w, err.Error(), status)
+			return
+		}
+	}
+	utils.SendError(w, fallback, http.StatusInternalServerError)
+}
+
+// decodeScheduleRequest reads and validates an access schedule body
+func decodeScheduleRequest(w http.ResponseWriter, r *http.Request) (*models.AccessSchedule, bool) {
+	var schedule models.AccessSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := utils.ValidateAccessSchedule(&schedule); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &schedule, true
+}
+
+// AccessSchedulesHandler lists (GET) or creates (POST) access schedules (/access-schedules)
+func AccessSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if !requirePermission(w, r, policy.ScheduleManage, "Only admins can manage access schedules") {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedules, err := utils.ListAccessSchedules()
+		if err != nil {
+			utils.SendError(w, "Failed to fetch access schedules", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, schedules, http.StatusOK)
+
+	case http.MethodPost:
+		schedule, ok := decodeScheduleRequest(w, r)
+		if !ok {
+			return
+		}
+		schedule.ID = 0
+		if err := utils.CreateAccessSchedule(schedule); err != nil {
+			sendScheduleError(w, err, "Failed to create access schedule")
+			return
+		}
+		utils.SendJSON(w, schedule, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AccessScheduleHandler reads (GET), replaces (PUT) or deletes (DELETE) one
+// access schedule (/access-schedules/{id})
+func AccessScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if !requirePermission(w, r, policy.ScheduleManage, "Only admins can manage access schedules") {
+		return
+	}
+
+	scheduleID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/access-schedules/"), 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid access schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedule, err := utils.GetAccessSchedule(uint(scheduleID))
+		if err != nil {
+			sendScheduleError(w, err, "Failed to fetch access schedule")
+			return
+		}
+		utils.SendJSON(w, schedule, http.StatusOK)
+
+	case http.MethodPut:
+		existing, err := utils.GetAccessSchedule(uint(scheduleID))
+		if err != nil {
+			sendScheduleError(w, err, "Failed to update access schedule")
+			return
+		}
+		schedule, ok := decodeScheduleRequest(w, r)
+		if !ok {
+			return
+		}
+		schedule.Model = existing.Model
+		if err := utils.UpdateAccessSchedule(schedule); err != nil {
+			sendScheduleError(w, err, "Failed to update access schedule")
+			return
+		}
+		utils.SendJSON(w, schedule, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := utils.DeleteAccessSchedule(uint(scheduleID)); err != nil {
+			sendScheduleError(w, err, "Failed to delete access schedule")
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Access schedule deleted",
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays,omitempty"` // omit for a key that does not expire
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string         `json:"key"` // shown once
+	APIKey *models.APIKey `json:"apiKey"`
+}
+
+// APIKeysHandler creates (POST) and lists (GET) the current user's API keys (/api-keys)
+func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := utils.GetUserAPIKeys(user.ID)
+		if err != nil {
+			utils.SendError(w, "Failed to fetch API keys", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, keys, http.StatusOK)
+	case http.MethodPost:
+		createAPIKey(w, r, user)
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createAPIKey(w http.ResponseWriter, r *http.Request, user *models.User) {
+	// A key would outlive the link and drop its view group restriction
+	if policy.FromLoginLink(user) {
+		utils.SendError(w, "API keys cannot be created from a login-link session", http.StatusForbidden)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		utils.SendError(w, "name is required and must be at most 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ValidateAPIKeyScopes(req.Scopes); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != 0 {
+		if req.ExpiresInDays < 0 || req.ExpiresInDays > config.API_KEY_MAX_EXPIRY_DAYS {
+			utils.SendError(w, "expiresInDays must be between 1 and "+strconv.Itoa(config.API_KEY_MAX_EXPIRY_DAYS), http.StatusBadRequest)
+			return
+		}
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	rawKey, key, err := utils.CreateAPIKey(user, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		utils.SendError(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, CreateAPIKeyResponse{
+		Key:    rawKey,
+		APIKey: key,
+	}, http.StatusCreated)
+}
+
+// RevokeAPIKeyHandler revokes an API key (/api-keys/{id}). Owners can revoke
+// their own keys and admins can revoke any key.
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	keyID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/api-keys/"), 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	var key models.APIKey
+	if db.DB.First(&key, keyID).Error != nil {
+		utils.SendError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	// Keys are in their owner's area; those of a deleted user need a global grant
+	var owner models.User
+	if db.DB.First(&owner, key.UserID).Error != nil {
+		if policy.Scope(user, policy.APIKeyManage) != policy.ScopeAll {
+			utils.SendError(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		owner.ID = key.UserID
+	}
+	if policy.Authorize(user, policy.APIKeyManage, policy.OwnedBy(&owner)) != nil {
+		utils.SendError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	if err := utils.RevokeAPIKey(key.ID); err != nil {
+		utils.SendError(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "API key revoked",
+	}, http.StatusOK)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type AreaMembershipRequest struct {
+	GroupID int    `json:"groupId"`
+	Role    string `json:"role"`
+}
+
+// UserAreasHandler lists (GET) or sets (POST) a user's extra area memberships
+// (/users/{id}/areas) and removes one (DELETE /users/{id}/areas/{groupId})
+func UserAreasHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	actor, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	// Split /users/5/areas[/3]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/users/"), "/")
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || len(parts) < 2 || parts[1] != "areas" {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if db.DB.First(&user, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		if user.ID != actor.ID {
+			if err := policy.CanManageUser(actor, &user); err != nil {
+				utils.SendError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		memberships, err := utils.GetUserMemberships(user.ID)
+		if err != nil {
+			utils.SendError(w, "Failed to fetch memberships", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, memberships, http.StatusOK)
+
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		if err := policy.CanManageUser(actor, &user); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		setUserArea(w, r, actor, &user)
+
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		if err := policy.CanManageUser(actor, &user); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		groupID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			utils.SendError(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+		var membership models.AreaMembership
+		if db.DB.Where("user_id = ? AND group_id = ?", user.ID, groupID).First(&membership).Error != nil {
+			utils.SendError(w, "Membership not found", http.StatusNotFound)
+			return
+		}
+		if err := policy.CanAssignRole(actor, membership.Role, membership.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := utils.RemoveAreaMembership(membership.ID); err != nil {
+			utils.SendError(w, "Failed to remove membership", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Membership removed",
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setUserArea adds user to an area or changes their role there. The caller
+// must already be able to manage user, to assign the role in that area, and
+// to take away the role it replaces.
+func setUserArea(w http.ResponseWriter, r *http.Request, actor, user *models.User) {
+	var req AreaMembershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.GroupID == 0 || req.Role == "" {
+		utils.SendError(w, "groupId and role are required", http.StatusBadRequest)
+		return
+	}
+	if req.GroupID == user.GroupId {
+		utils.SendError(w, "This is already the user's home area", http.StatusBadRequest)
+		return
+	}
+	if err := utils.ValidateRole(req.Role); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	area, err := utils.ResolveArea(req.GroupID)
+	if err != nil {
+		utils.SendError(w, "Unknown area", http.StatusBadRequest)
+		return
+	}
+
+	if err := policy.CanAssignRole(actor, req.Role, req.GroupID); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var existing models.AreaMembership
+	if db.DB.Where("user_id = ? AND group_id = ?", user.ID, req.GroupID).First(&existing).Error == nil {
+		if err := policy.CanAssignRole(actor, existing.Role, existing.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	membership, err := utils.SetAreaMembership(user.ID, area.ID, area.Name, req.Role)
+	if err != nil {
+		utils.SendError(w, "Failed to save membership", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, membership, http.StatusOK)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type AreaRequest struct {
+	Name            string `json:"name"`
+	ParentID        *int   `json:"parentId"`
+	IsHQ            bool   `json:"isHQ"`
+	IncludeChildren bool   `json:"includeChildren"`
+}
+
+// areaErrorStatus maps area store errors to HTTP statuses
+var areaErrorStatus = map[error]int{
+	utils.ErrAreaNotFound:    http.StatusNotFound,
+	utils.ErrAreaNameTaken:   http.StatusConflict,
+	utils.ErrAreaCycle:       http.StatusBadRequest,
+	utils.ErrAreaHasChildren: http.StatusConflict,
+	utils.ErrAreaInUse:       http.StatusConflict,
+}
+
+// sendAreaError responds with the status for an area store error
+func sendAreaError(w http.ResponseWriter, err error, fallback string) {
+	for areaErr, status := range areaErrorStatus {
+		if errors.Is(err, areaErr) {
+			utils.SendError(w, err.Error(), status)
+			return
+		}
+	}
+	utils.SendError(w, fallback, http.StatusInternalServerError)
+}
+
+// decodeAreaRequest reads and validates an area body
+func decodeAreaRequest(w http.ResponseWriter, r *http.Request) (*AreaRequest, bool) {
+	var req AreaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 191 {
+		utils.SendError(w, "name is required (max 191 characters)", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// AreasHandler lists (GET) or creates (POST) areas (/areas)
+func AreasHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, err := utils.GetUserFromSession(r); err != nil {
+			utils.SendAuthError(w, err)
+			return
+		}
+		areas, err := utils.ListAreas()
+		if err != nil {
+			utils.SendError(w, "Failed to fetch areas", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, areas, http.StatusOK)
+
+	case http.MethodPost:
+		if !requirePermission(w, r, policy.AreaManage, "Only admins can manage areas") {
+			return
+		}
+		req, ok := decodeAreaRequest(w, r)
+		if !ok {
+			return
+		}
+
+		area := models.Area{
+			Name:            req.Name,
+			ParentID:        req.ParentID,
+			IsHQ:            req.IsHQ,
+			IncludeChildren: req.IncludeChildren,
+		}
+		if err := utils.CreateArea(&area); err != nil {
+			sendAreaError(w, err, "Failed to create area")
+			return
+		}
+		utils.SendJSON(w, area, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AreaHandler reads (GET), updates (PUT) or deletes (DELETE) one area
+// (/areas/{id}). Renaming an area renames it everywhere it is stored.
+func AreaHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	areaID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/areas/"))
+	if err != nil {
+		utils.SendError(w, "Invalid area ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, err := utils.GetUserFromSession(r); err != nil {
+			utils.SendAuthError(w, err)
+			return
+		}
+		area, err := utils.GetArea(areaID)
+		if err != nil {
+			sendAreaError(w, err, "Failed to fetch area")
+			return
+		}
+		utils.SendJSON(w, area, http.StatusOK)
+
+	case http.MethodPut:
+		if !requirePermission(w, r, policy.AreaManage, "Only admins can manage areas") {
+			return
+		}
+		req, ok := decodeAreaRequest(w, r)
+		if !ok {
+			return
+		}
+		area, err := utils.GetArea(areaID)
+		if err != nil {
+			sendAreaError(w, err, "Failed to update area")
+			return
+		}
+
+		previousName := area.Name
+		area.Name = req.Name
+		area.ParentID = req.ParentID
+		area.IsHQ = req.IsHQ
+		area.IncludeChildren = req.IncludeChildren
+		if err := utils.UpdateArea(area, previousName); err != nil {
+			sendAreaError(w, err, "Failed to update area")
+			return
+		}
+		utils.SendJSON(w, area, http.StatusOK)
+
+	case http.MethodDelete:
+		if !requirePermission(w, r, policy.AreaManage, "Only admins can manage areas") {
+			return
+		}
+		if err := utils.DeleteArea(areaID); err != nil {
+			sendAreaError(w, err, "Failed to delete area")
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Area deleted",
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"go-auth/config"
-	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 	"go-auth/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Request/Response structures
@@ -23,6 +24,7 @@ type LoginResponse struct {
 	GroupId  int    `json:"groupId"`
 	AreaName string `json:"areaName"`
 	Role     string `json:"role"`
+	Expires  string `json:"expires"`
 }
 
 // AuthHandler handles JSON login requests
@@ -50,41 +52,21 @@ func AuthHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find user in database
-	var user models.User
-	result := db.DB.Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
-		utils.SendError(w, "Invalid credentials", http.StatusUnauthorized)
-		return
-	}
-
-	// Verify password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	// Verify credentials (throttled per username and IP)
+	user, err := utils.CheckCredentials(r, req.Username, req.Password)
 	if err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "throttled", nil, req.Username)
+			utils.SendTooManyAttempts(w, throttleErr)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "invalid_credentials", nil, req.Username)
 		utils.SendError(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	// Create session JWT
-	sessionToken, err := utils.CreateSessionJWT(user)
-	if err != nil {
-		utils.SendError(w, "Failed to create session", http.StatusInternalServerError)
-		return
-	}
-
-	// Set session cookie
-	utils.SetSessionCookie(w, sessionToken)
-
-	// Return user data
-	response := LoginResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		GroupId:  user.GroupId,
-		AreaName: user.AreaName,
-		Role:     user.Role,
-	}
-
-	utils.SendJSON(w, response, http.StatusOK)
+	sendLoginResult(w, r, user, false)
 }
 
 // LoginFormHandler handles form-based login requests
@@ -116,30 +98,145 @@ func LoginFormHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find user
-	var user models.User
-	result := db.DB.Where("username = ?", username).First(&user)
-	if result.Error != nil {
+	// Verify credentials (throttled per username and IP, same as JSON login)
+	user, err := utils.CheckCredentials(r, username, password)
+	if err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "throttled", nil, username)
+			utils.SendTooManyAttempts(w, throttleErr)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "invalid_credentials", nil, username)
 		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=invalid_credentials", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	redirectLoginResult(w, r, user, false)
+}
+
+// recordLoginEvent adds a login attempt to the auth event log. user may be nil
+// for failures, in which case username is the name that was tried.
+func recordLoginEvent(r *http.Request, method, outcome, reason string, user *models.User, username string) {
+	utils.RecordAuthEvent(r, models.AuthEvent{
+		Event:    utils.AuthEventLogin,
+		Method:   method,
+		Outcome:  outcome,
+		Reason:   reason,
+		Username: username,
+	}, user)
+}
+
+// checkLoginSchedule returns the error from user's access schedule when they
+// may not log in now, recording the refused login
+func checkLoginSchedule(r *http.Request, user *models.User, method string) error {
+	err := policy.CheckSchedule(user, time.Now())
 	if err != nil {
-		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=invalid_credentials", http.StatusTemporaryRedirect)
+		recordLoginEvent(r, method, utils.AuthOutcomeFailure, "outside_schedule", user, "")
+	}
+	return err
+}
+
+// sendLoginResult finishes a JSON login after the password step (and MFA, if mfaVerified).
+// Users who still owe a TOTP code or a new password get that challenge instead of a session.
+func sendLoginResult(w http.ResponseWriter, r *http.Request, user *models.User, mfaVerified bool) {
+	if err := checkLoginSchedule(r, user, utils.LoginMethodPassword); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
 		return
 	}
 
-	// Create session JWT
-	sessionToken, err := utils.CreateSessionJWT(user)
+	if user.TOTPEnabled && !mfaVerified {
+		mfaToken, err := createMFAChallenge(*user)
+		if err != nil {
+			utils.SendError(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeChallenge, "mfa_required", user, "")
+		utils.SendJSON(w, MFAChallengeResponse{MFARequired: true, MFAToken: mfaToken}, http.StatusOK)
+		return
+	}
+
+	if utils.PasswordChangeRequired(user) {
+		changeToken, err := createPasswordChangeChallenge(*user)
+		if err != nil {
+			utils.SendError(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeChallenge, "password_change_required", user, "")
+		utils.SendJSON(w, PasswordChangeRequiredResponse{PasswordChangeRequired: true, ChangeToken: changeToken}, http.StatusOK)
+		return
+	}
+
+	sendNewSession(w, r, user, utils.LoginMethodPassword)
+}
+
+// sendNewSession starts a session for user and returns the login response
+func sendNewSession(w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	// Start session and set access/refresh cookies
+	expiresAt, err := utils.IssueSession(w, r, *user, method)
 	if err != nil {
-		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=session_failed", http.StatusTemporaryRedirect)
+		utils.SendError(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
+	recordLoginEvent(r, method, utils.AuthOutcomeSuccess, "", user, "")
 
-	// Set session cookie
-	utils.SetSessionCookie(w, sessionToken)
+	// Return user data
+	response := LoginResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		GroupId:  user.GroupId,
+		AreaName: user.AreaName,
+		Role:     user.Role,
+		Expires:  expiresAt.Format(time.RFC3339),
+	}
+
+	utils.SendJSON(w, response, http.StatusOK)
+}
+
+// redirectLoginResult is the form-login counterpart of sendLoginResult
+func redirectLoginResult(w http.ResponseWriter, r *http.Request, user *models.User, mfaVerified bool) {
+	if err := checkLoginSchedule(r, user, utils.LoginMethodPassword); err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=outside_schedule", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Users with TOTP enabled continue on the two-factor page
+	if user.TOTPEnabled && !mfaVerified {
+		mfaToken, err := createMFAChallenge(*user)
+		if err != nil {
+			http.Redirect(w, r, config.FRONTEND_URL+"/login?error=session_failed", http.StatusTemporaryRedirect)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeChallenge, "mfa_required", user, "")
+		utils.SetMFACookie(w, mfaToken)
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/mfa", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Users with an expired or flagged password continue on the change-password page
+	if utils.PasswordChangeRequired(user) {
+		changeToken, err := createPasswordChangeChallenge(*user)
+		if err != nil {
+			http.Redirect(w, r, config.FRONTEND_URL+"/login?error=session_failed", http.StatusTemporaryRedirect)
+			return
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeChallenge, "password_change_required", user, "")
+		utils.SetPasswordChangeCookie(w, changeToken)
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/change-password", http.StatusTemporaryRedirect)
+		return
+	}
+
+	redirectNewSession(w, r, user, utils.LoginMethodPassword)
+}
+
+// redirectNewSession starts a session for user and redirects to the frontend
+func redirectNewSession(w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	// Start session and set access/refresh cookies
+	if _, err := utils.IssueSession(w, r, *user, method); err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=session_failed", http.StatusTemporaryRedirect)
+		return
+	}
+	recordLoginEvent(r, method, utils.AuthOutcomeSuccess, "", user, "")
 
 	// Redirect to home
 	http.Redirect(w, r, config.FRONTEND_URL+"/", http.StatusTemporaryRedirect)
@@ -153,10 +250,53 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Clear session cookie
+	// Logout changes state, so it is POST-only and CSRF-checked like any other mutation
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Find the session from the access JWT, or from the refresh token once the JWT has expired
+	var session *models.Session
+	if cookie, err := utils.GetSessionCookie(r); err == nil {
+		if _, current, err := utils.VerifySessionToken(cookie.Value); err == nil {
+			if err := utils.CheckCSRFToken(r, current); err != nil {
+				utils.SendAuthError(w, err)
+				return
+			}
+			session = current
+		}
+	}
+	if session == nil {
+		if cookie, err := utils.GetRefreshCookie(r); err == nil {
+			if err := utils.CheckRefreshCSRFToken(r, cookie.Value); err != nil {
+				utils.SendAuthError(w, err)
+				return
+			}
+			session, _ = utils.RefreshTokenSession(cookie.Value)
+		}
+	}
+
+	// Revoke the server-side session so the JWT can't be reused
+	if session != nil {
+		if err := utils.EndSession(r, session); err != nil {
+			utils.SendError(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		utils.RecordAuthEvent(r, models.AuthEvent{
+			Event:    utils.AuthEventLogout,
+			Method:   session.LoginMethod,
+			Outcome:  utils.AuthOutcomeSuccess,
+			Username: session.Username,
+		}, nil)
+	}
+
+	// Clear session cookies
 	utils.ClearSessionCookie(w)
+	utils.ClearRefreshCookie(w)
+	utils.ClearCSRFCookie(w)
 
-	http.Redirect(w, r, config.FRONTEND_URL+"/login", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, config.FRONTEND_URL+"/login", http.StatusSeeOther)
 }
 
 // SessionHandler returns current session information
@@ -180,13 +320,45 @@ func SessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify session JWT
-	payload, err := utils.VerifySessionJWT(cookie.Value)
+	payload, session, err := utils.VerifySessionToken(cookie.Value)
 	if err != nil {
 		utils.SendJSON(w, map[string]interface{}{"user": nil}, http.StatusOK)
 		return
 	}
 
-	// Return session data
+	if err := utils.CheckImpersonation(r, session); err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	// Return session data with the access token expiry so the frontend knows when to refresh
+	exp, _ := payload["exp"].(float64)
+	expires := time.Unix(int64(exp), 0)
+
+	// The frontend may live on another host and be unable to read our CSRF cookie
+	csrfToken, err := utils.EnsureCSRFToken(session)
+	if err != nil {
+		utils.SendError(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	memberships, err := utils.GetUserMemberships(session.UserID)
+	if err != nil {
+		utils.SendError(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	// Flag impersonation so the frontend can show who is really signed in
+	var impersonation interface{}
+	if session.ImpersonatorID != nil {
+		impersonation = map[string]interface{}{
+			"impersonatorId":   *session.ImpersonatorID,
+			"impersonatorName": session.ImpersonatorName,
+			"allowDestructive": session.AllowDestructive,
+			"endsAt":           session.ExpiresAt.Format(time.RFC3339),
+		}
+	}
+
 	utils.SendJSON(w, map[string]interface{}{
 		"user": map[string]interface{}{
 			"id":       payload["id"],
@@ -196,5 +368,51 @@ func SessionHandler(w http.ResponseWriter, r *http.Request) {
 			"areaName": payload["areaName"],
 			"role":     payload["role"],
 		},
+		"memberships":   memberships,
+		"expires":       expires.Format(time.RFC3339),
+		"csrfToken":     csrfToken,
+		"readOnly":      session.ReadOnly,
+		"viewGroupIds":  session.ViewGroupIDs,
+		"impersonating": session.ImpersonatorID != nil,
+		"impersonation": impersonation,
+	}, http.StatusOK)
+}
+
+// RefreshHandler rotates the refresh token and issues a new access token
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := utils.GetRefreshCookie(r)
+	if err != nil {
+		utils.SendError(w, "No refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// Checked before rotating so a cross-site request can't burn the refresh token
+	if err := utils.CheckRefreshCSRFToken(r, cookie.Value); err != nil {
+		utils.SendError(w, "Invalid or missing CSRF token", http.StatusForbidden)
+		return
+	}
+
+	expiresAt, err := utils.RefreshSession(w, r, cookie.Value)
+	if err != nil {
+		utils.ClearSessionCookie(w)
+		utils.ClearRefreshCookie(w)
+		utils.ClearCSRFCookie(w)
+		utils.SendError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"expires": expiresAt.Format(time.RFC3339),
 	}, http.StatusOK)
 }
\ No newline at end of file
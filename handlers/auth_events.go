@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-auth/utils"
+)
+
+// AuthEventsHandler returns a page of login history (/auth-events). Admins see
+// every event, Area Admins their area's users and everyone else their own.
+func AuthEventsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := utils.AuthEventFilter{
+		Username: query.Get("username"),
+		Event:    query.Get("event"),
+		Method:   query.Get("method"),
+		Outcome:  query.Get("outcome"),
+	}
+
+	if value := query.Get("userId"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.SendError(w, "Invalid userId", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		utils.SendError(w, "Invalid from time, expected RFC3339", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		utils.SendError(w, "Invalid to time, expected RFC3339", http.StatusBadRequest)
+		return
+	}
+
+	filter.Page, err = strconv.Atoi(query.Get("page"))
+	if err != nil || filter.Page <= 0 {
+		filter.Page = 1
+	}
+	filter.PageSize, err = strconv.Atoi(query.Get("pageSize"))
+	if err != nil || filter.PageSize <= 0 {
+		filter.PageSize = 50
+	}
+	if filter.PageSize > 200 {
+		filter.PageSize = 200
+	}
+
+	events, total, err := utils.QueryAuthEvents(user, filter)
+	if err != nil {
+		utils.SendError(w, "Failed to fetch auth events", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"events":   events,
+		"page":     filter.Page,
+		"pageSize": filter.PageSize,
+		"total":    total,
+	}, http.StatusOK)
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type CameraRuleRequest struct {
+	CameraID    string `json:"cameraId"`
+	SubjectType string `json:"subjectType"`
+	SubjectID   string `json:"subjectId"`
+	Effect      string `json:"effect"`
+}
+
+// CameraRulesHandler lists (GET, optionally ?cameraId=) or adds (POST) camera
+// visibility rules (/camera-rules)
+func CameraRulesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if !requirePermission(w, r, policy.CameraManage, "Only admins can manage camera rules") {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := utils.ListCameraRules(r.URL.Query().Get("cameraId"))
+		if err != nil {
+			utils.SendError(w, "Failed to fetch camera rules", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, rules, http.StatusOK)
+
+	case http.MethodPost:
+		user, err := utils.GetUserFromSession(r)
+		if err != nil {
+			utils.SendAuthError(w, err)
+			return
+		}
+
+		var req CameraRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		rule := models.CameraRule{
+			CameraID:    req.CameraID,
+			SubjectType: req.SubjectType,
+			SubjectID:   strings.TrimSpace(req.SubjectID),
+			Effect:      req.Effect,
+			CreatedBy:   user.Username,
+		}
+		if err := utils.ValidateCameraRule(&rule); err != nil {
+			utils.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.CreateCameraRule(&rule); err != nil {
+			utils.SendError(w, "Failed to save camera rule", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, rule, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// CameraRuleHandler removes one camera rule (DELETE /camera-rules/{id})
+func CameraRuleHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.CameraManage, "Only admins can manage camera rules") {
+		return
+	}
+
+	ruleID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/camera-rules/"), 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid camera rule ID", http.StatusBadRequest)
+		return
+	}
+
+	found, err := utils.DeleteCameraRule(uint(ruleID))
+	if err != nil {
+		utils.SendError(w, "Failed to delete camera rule", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		utils.SendError(w, "Camera rule not found", http.StatusNotFound)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Camera rule deleted",
+	}, http.StatusOK)
+}
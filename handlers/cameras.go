@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type CameraRequest struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	GroupID      int    `json:"groupId"`
+	StreamURL    string `json:"streamUrl"`
+	SnapshotURL  string `json:"snapshotUrl"`
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
+	Location     string `json:"location"`
+	Enabled      *bool  `json:"enabled"` // defaults to true
+}
+
+// cameraErrorStatus maps camera registry errors to HTTP statuses
+var cameraErrorStatus = map[error]int{
+	utils.ErrCameraNotFound: http.StatusNotFound,
+	utils.ErrCameraExists:   http.StatusConflict,
+	utils.ErrCameraInUse:    http.StatusConflict,
+}
+
+// sendCameraError responds with the status for a camera registry error
+func sendCameraError(w http.ResponseWriter, err error, fallback string) {
+	for cameraErr, status := range cameraErrorStatus {
+		if errors.Is(err, cameraErr) {
+			utils.SendError(w, err.Error(), status)
+			return
+		}
+	}
+	utils.SendError(w, fallback, http.StatusInternalServerError)
+}
+
+// sendCameraUseError responds to a failed CheckNewCameras: 403 when the caller
+// may not use a camera, 400 when it is unknown or disabled
+func sendCameraUseError(w http.ResponseWriter, err error) {
+	if errors.Is(err, policy.ErrForbidden) {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, utils.ErrCameraNotFound) || errors.Is(err, utils.ErrCameraDisabled) {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.SendError(w, "Failed to check cameras", http.StatusInternalServerError)
+}
+
+// decodeCameraRequest reads and validates a camera body
+func decodeCameraRequest(w http.ResponseWriter, r *http.Request) (*CameraRequest, bool) {
+	var req CameraRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.GroupID == 0 {
+		utils.SendError(w, "name and groupId are required", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// applyCameraRequest copies a request onto camera, taking the area name from the area record
+func applyCameraRequest(w http.ResponseWriter, req *CameraRequest, camera *models.Camera) bool {
+	area, err := utils.ResolveArea(req.GroupID)
+	if err != nil {
+		utils.SendError(w, "Unknown area", http.StatusBadRequest)
+		return false
+	}
+
+	camera.Name = req.Name
+	camera.GroupID = area.ID
+	camera.AreaName = area.Name
+	camera.StreamURL = req.StreamURL
+	camera.SnapshotURL = req.SnapshotURL
+	camera.Manufacturer = req.Manufacturer
+	camera.ModelName = req.Model
+	camera.Location = req.Location
+	if req.Enabled != nil {
+		camera.Enabled = *req.Enabled
+	}
+	return true
+}
+
+// CamerasHandler searches (GET ?q=&groupId=&enabled=) or registers (POST)
+// cameras (/cameras). Searches only return cameras the caller may use.
+func CamerasHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		search := utils.CameraSearch{Query: strings.TrimSpace(query.Get("q"))}
+		if value := query.Get("groupId"); value != "" {
+			if search.GroupID, err = strconv.Atoi(value); err != nil {
+				utils.SendError(w, "Invalid groupId", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := query.Get("enabled"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				utils.SendError(w, "Invalid enabled", http.StatusBadRequest)
+				return
+			}
+			search.Enabled = &enabled
+		}
+
+		cameras, err := utils.SearchCameras(user, search)
+		if err != nil {
+			utils.SendError(w, "Failed to fetch cameras", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, cameras, http.StatusOK)
+
+	case http.MethodPost:
+		req, ok := decodeCameraRequest(w, r)
+		if !ok {
+			return
+		}
+		req.ID = strings.TrimSpace(req.ID)
+		if req.ID == "" || len(req.ID) > 191 || strings.Contains(req.ID, "/") {
+			utils.SendError(w, "id is required (max 191 characters, no '/')", http.StatusBadRequest)
+			return
+		}
+		if err := utils.CanManageCamera(user, req.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		camera := models.Camera{
+			ID:        req.ID,
+			Enabled:   true,
+			CreatedBy: user.Username,
+			UpdatedBy: user.Username,
+		}
+		if !applyCameraRequest(w, req, &camera) {
+			return
+		}
+		if err := utils.CreateCamera(&camera); err != nil {
+			sendCameraError(w, err, "Failed to create camera")
+			return
+		}
+		utils.SendJSON(w, camera, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// CameraHandler reads (GET), updates (PUT) or deletes (DELETE) one camera
+// (/cameras/{id}). Renaming a camera renames its map positions too.
+func CameraHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	camera, err := utils.GetCamera(strings.TrimPrefix(r.URL.Path, "/cameras/"))
+	if err != nil {
+		sendCameraError(w, err, "Failed to fetch camera")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := utils.CanUseCamera(user, camera); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		utils.SendJSON(w, camera, http.StatusOK)
+
+	case http.MethodPut:
+		if err := utils.CanManageCamera(user, camera.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		req, ok := decodeCameraRequest(w, r)
+		if !ok {
+			return
+		}
+		// Moving the camera to another area needs manage rights there
+		if req.GroupID != camera.GroupID {
+			if err := utils.CanManageCamera(user, req.GroupID); err != nil {
+				utils.SendError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		previous := *camera
+		if !applyCameraRequest(w, req, camera) {
+			return
+		}
+		camera.UpdatedBy = user.Username
+		if err := utils.UpdateCamera(camera, previous); err != nil {
+			sendCameraError(w, err, "Failed to update camera")
+			return
+		}
+		utils.SendJSON(w, camera, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := utils.CanManageCamera(user, camera.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := utils.DeleteCamera(camera.ID); err != nil {
+			sendCameraError(w, err, "Failed to delete camera")
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Camera deleted",
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
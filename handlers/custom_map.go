@@ -9,6 +9,7 @@ import (
 
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 	"go-auth/utils"
 )
 
@@ -47,7 +48,7 @@ func GetCustomMapsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -71,7 +72,7 @@ func CreateCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -93,6 +94,19 @@ func CreateCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 		utils.SendError(w, err.Error(), http.StatusForbidden)
 		return
 	}
+	// The area must exist; its name is taken from the area record
+	area, err := utils.ResolveArea(req.GroupID)
+	if err != nil {
+		utils.SendError(w, "Unknown area", http.StatusBadRequest)
+		return
+	}
+	req.AreaName = area.Name
+
+	// Every camera must be registered and usable by the user
+	if err := utils.CheckNewCameras(user, cameraPositionIDs(req.Cameras), nil); err != nil {
+		sendCameraUseError(w, err)
+		return
+	}
 
 	// Convert bounds to JSON
 	boundsJSON := ""
@@ -154,7 +168,7 @@ func GetCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -171,7 +185,7 @@ func GetCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user has access to this map
-	if user.Role != "admin" && customMap.GroupID != user.GroupId {
+	if utils.CanViewCustomMap(user, &customMap) != nil {
 		utils.SendError(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -181,7 +195,7 @@ func GetCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 
 	utils.SendJSON(w, map[string]interface{}{
 		"map":     customMap,
-		"cameras": cameras,
+		"cameras": utils.FilterCameraPositions(user, cameras),
 	}, http.StatusOK)
 }
 
@@ -195,7 +209,7 @@ func UpdateCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -224,6 +238,27 @@ func UpdateCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Moving the map to another area needs create rights there
+	req.AreaName = ""
+	if req.GroupID != 0 && req.GroupID != existing.GroupID {
+		if err := utils.CanCreateCustomMap(user, req.GroupID); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		area, err := utils.ResolveArea(req.GroupID)
+		if err != nil {
+			utils.SendError(w, "Unknown area", http.StatusBadRequest)
+			return
+		}
+		req.AreaName = area.Name
+	}
+
+	// Cameras added by this update must be registered and usable by the user
+	if err := utils.CheckNewCameras(user, cameraPositionIDs(req.Cameras), utils.MapCameraIDs(mapID)); err != nil {
+		sendCameraUseError(w, err)
+		return
+	}
+
 	// Convert bounds
 	boundsJSON := ""
 	if req.Bounds != nil {
@@ -246,10 +281,18 @@ func UpdateCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 		UpdatedBy:   user.Username, // Track who updated it
 	})
 
-	// Delete old cameras and add new ones
-	db.DB.Where("custom_map_id = ?", mapID).Delete(&models.CameraPosition{})
+	// Delete old cameras and add new ones. Cameras hidden from the user were
+	// not sent to them, so their positions are kept as they are.
+	deleteQuery := db.DB.Where("custom_map_id = ?", mapID)
+	if hidden := utils.HiddenCameraPositionIDs(user, mapID); len(hidden) > 0 {
+		deleteQuery = deleteQuery.Where("id NOT IN ?", hidden)
+	}
+	deleteQuery.Delete(&models.CameraPosition{})
 
 	for _, cam := range req.Cameras {
+		if !policy.CanViewCamera(user, cam.CameraID) {
+			continue
+		}
 		cameraPos := models.CameraPosition{
 			CustomMapID: mapID,
 			CameraID:    cam.CameraID,
@@ -278,7 +321,7 @@ func DeleteCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -300,8 +343,9 @@ func DeleteCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete cameras first, then map
+	// Delete cameras and grants first, then map
 	db.DB.Where("custom_map_id = ?", mapID).Delete(&models.CameraPosition{})
+	utils.DeleteResourceGrants(policy.ResourceCustomMap, strconv.FormatUint(uint64(mapID), 10))
 	db.DB.Delete(&customMap)
 
 	utils.SendJSON(w, map[string]interface{}{
@@ -309,6 +353,15 @@ func DeleteCustomMapHandler(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
+// cameraPositionIDs returns the camera IDs of requested map positions
+func cameraPositionIDs(cameras []CameraPositionRequest) []string {
+	ids := make([]string, len(cameras))
+	for i, cam := range cameras {
+		ids[i] = cam.CameraID
+	}
+	return ids
+}
+
 // Helper to parse map ID from URL
 func parseMapID(r *http.Request) (uint, error) {
 	path := strings.TrimPrefix(r.URL.Path, "/custom-maps/")
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type EnrollDeviceRequest struct {
+	Name        string `json:"name"`
+	ViewGroupID string `json:"viewGroupId"`
+}
+
+type EnrollDeviceResponse struct {
+	Credential string         `json:"credential"` // shown once
+	Device     *models.Device `json:"device"`
+}
+
+type UpdateDeviceRequest struct {
+	Name        string `json:"name,omitempty"`
+	ViewGroupID string `json:"viewGroupId,omitempty"`
+}
+
+// DevicesHandler lists (GET) or enrolls (POST) video-wall devices (/devices)
+func DevicesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Areas(admin, policy.DeviceManage).None() {
+		utils.SendError(w, "Only admins can manage devices", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		devices, err := utils.ListDevices(admin)
+		if err != nil {
+			utils.SendError(w, "Failed to fetch devices", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, devices, http.StatusOK)
+
+	case http.MethodPost:
+		var req EnrollDeviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 255 {
+			utils.SendError(w, "name is required and must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		viewGroup, err := utils.GetViewGroupByID(req.ViewGroupID)
+		if err != nil {
+			utils.SendError(w, "View group not found", http.StatusBadRequest)
+			return
+		}
+		if err := policy.Authorize(admin, policy.DeviceManage, policy.InArea(viewGroup.GroupID)); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		credential, device, err := utils.EnrollDevice(req.Name, viewGroup, admin.Username)
+		if err != nil {
+			utils.SendError(w, "Failed to enroll device", http.StatusInternalServerError)
+			return
+		}
+
+		utils.SendJSON(w, EnrollDeviceResponse{
+			Credential: credential,
+			Device:     device,
+		}, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DeviceHandler renames or reassigns (PUT) and revokes (DELETE) a device (/devices/{id})
+func DeviceHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	deviceID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/devices/"), 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid device ID", http.StatusBadRequest)
+		return
+	}
+
+	var device models.Device
+	if db.DB.First(&device, deviceID).Error != nil {
+		utils.SendError(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	// Devices belong to the area of their view group, like enrolment
+	if err := policy.Authorize(admin, policy.DeviceManage, policy.InArea(device.GroupId)); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := utils.RevokeDevice(&device); err != nil {
+			utils.SendError(w, "Failed to revoke device", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Device revoked",
+		}, http.StatusOK)
+		return
+	}
+
+	if device.RevokedAt != nil {
+		utils.SendError(w, "Device has been revoked", http.StatusConflict)
+		return
+	}
+
+	var req UpdateDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if len(name) > 255 {
+			utils.SendError(w, "name must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+		if err := db.DB.Model(&device).Update("name", name).Error; err != nil {
+			utils.SendError(w, "Failed to update device", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ViewGroupID != "" {
+		viewGroup, err := utils.GetViewGroupByID(req.ViewGroupID)
+		if err != nil {
+			utils.SendError(w, "View group not found", http.StatusBadRequest)
+			return
+		}
+		if err := policy.Authorize(admin, policy.DeviceManage, policy.InArea(viewGroup.GroupID)); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := utils.AssignDeviceViewGroup(&device, viewGroup); err != nil {
+			utils.SendError(w, "Failed to update device", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	utils.SetDeviceOnline(&device)
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Device updated",
+		"device":  device,
+	}, http.StatusOK)
+}
+
+// DeviceViewGroupHandler returns the view group assigned to the calling device (/device/view-group)
+func DeviceViewGroupHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	device, err := utils.GetDeviceFromRequest(r)
+	if err != nil {
+		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	viewGroup, err := utils.GetViewGroupByID(device.ViewGroupID)
+	if err != nil {
+		utils.SendError(w, "Assigned view group not found", http.StatusNotFound)
+		return
+	}
+
+	// Hide the cameras the camera rules do not allow for the device's area
+	utils.FilterViewGroupCameras(utils.DeviceViewer(device), viewGroup)
+
+	utils.SendJSON(w, map[string]interface{}{
+		"device":                   device,
+		"viewGroup":                viewGroup,
+		"heartbeatIntervalSeconds": config.DEVICE_HEARTBEAT_SECONDS,
+	}, http.StatusOK)
+}
+
+// DeviceHeartbeatHandler records that a device is alive and tells it which
+// view group to show, so it can refetch after a reassignment (/device/heartbeat)
+func DeviceHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	device, err := utils.GetDeviceFromRequest(r)
+	if err != nil {
+		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	response := map[string]interface{}{
+		"viewGroupId":              device.ViewGroupID,
+		"heartbeatIntervalSeconds": config.DEVICE_HEARTBEAT_SECONDS,
+	}
+	// Lets the device notice edits to its view group without refetching every time
+	if viewGroup, err := utils.GetViewGroupByID(device.ViewGroupID); err == nil {
+		response["viewGroupUpdatedAt"] = viewGroup.UpdatedAt
+	}
+
+	utils.SendJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type StartImpersonationRequest struct {
+	AllowDestructive bool   `json:"allowDestructive,omitempty"` // permit POST/PUT/DELETE as the user
+	Reason           string `json:"reason,omitempty"`           // recorded in the audit trail
+}
+
+// StartImpersonationHandler lets an admin view the app as another user (/users/{id}/impersonate)
+func StartImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Authorize(admin, policy.UserImpersonate, policy.Resource{}) != nil {
+		utils.SendError(w, "Only admins can impersonate users", http.StatusForbidden)
+		return
+	}
+
+	if admin.Auth.Method != "session" || admin.Auth.ImpersonatorID != nil {
+		utils.SendError(w, "Impersonation must be started from your own browser session", http.StatusConflict)
+		return
+	}
+
+	// Extract user ID from URL path (/users/5/impersonate)
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/impersonate")
+	userID, err := strconv.ParseUint(path, 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional
+	var req StartImpersonationRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var target models.User
+	if db.DB.First(&target, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := policy.CanImpersonate(admin, &target); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Users who could impersonate others themselves are never impersonated
+	if target.ID == admin.ID || policy.Authorize(&target, policy.UserImpersonate, policy.Resource{}) == nil {
+		utils.SendError(w, "Admins cannot be impersonated", http.StatusForbidden)
+		return
+	}
+
+	expiresAt, err := utils.StartImpersonation(w, r, admin, target, req.AllowDestructive, req.Reason)
+	if err != nil {
+		utils.SendError(w, "Failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message":          "Impersonation started",
+		"user":             target.Username,
+		"allowDestructive": req.AllowDestructive,
+		"expires":          expiresAt.Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+// StopImpersonationHandler ends impersonation and restores the admin's session (/impersonation/stop)
+func StopImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := utils.GetSessionCookie(r)
+	if err != nil {
+		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, session, err := utils.VerifySessionToken(cookie.Value)
+	if err != nil {
+		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := utils.CheckCSRFToken(r, session); err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if session.ImpersonatorID == nil {
+		utils.SendError(w, "Not impersonating", http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.StopImpersonation(w, r, session); err != nil {
+		// The admin's own session ended in the meantime; sign out completely
+		utils.ClearSessionCookie(w)
+		utils.ClearRefreshCookie(w)
+		utils.ClearCSRFCookie(w)
+		utils.SendJSON(w, map[string]interface{}{
+			"message":   "Impersonation stopped; please sign in again",
+			"signedOut": true,
+		}, http.StatusOK)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Impersonation stopped",
+	}, http.StatusOK)
+}
+
+// ImpersonationAuditHandler lists impersonation audit records for admins (/impersonation/audit)
+func ImpersonationAuditHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.AuditView, "Only admins can view the impersonation audit") {
+		return
+	}
+
+	query := r.URL.Query()
+	impersonatorID, _ := strconv.ParseUint(query.Get("impersonatorId"), 10, 32)
+	targetUserID, _ := strconv.ParseUint(query.Get("userId"), 10, 32)
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 200
+	}
+
+	records, err := utils.GetImpersonationAudit(uint(impersonatorID), uint(targetUserID), limit)
+	if err != nil {
+		utils.SendError(w, "Failed to fetch audit records", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, records, http.StatusOK)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type UnlockUserRequest struct {
+	IP string `json:"ip,omitempty"` // optionally also clear a blocked client IP
+}
+
+// UnlockUserHandler lets an admin clear a user's login lockout (/users/{id}/unlock)
+func UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Authorize(admin, policy.UserManage, policy.Resource{}) != nil {
+		utils.SendError(w, "Only admins can unlock accounts", http.StatusForbidden)
+		return
+	}
+
+	// Extract user ID from URL path (/users/5/unlock)
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/unlock")
+	userID, err := strconv.ParseUint(path, 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional
+	var req UnlockUserRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var user models.User
+	if db.DB.First(&user, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := policy.CanManageUser(admin, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := utils.UnlockLogin(user.Username, req.IP); err != nil {
+		utils.SendError(w, "Failed to unlock user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "User unlocked",
+		"user":    user.Username,
+	}, http.StatusOK)
+}
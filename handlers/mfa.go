@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+// Request/Response structures
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauthUri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// createMFAChallenge issues the pending-MFA token returned after a correct password
+func createMFAChallenge(user models.User) (string, error) {
+	expiresAt := time.Now().Add(time.Minute * config.MFA_CHALLENGE_EXPIRY_MINUTES)
+	return utils.CreateChallengeJWT(user.ID, "mfa", expiresAt)
+}
+
+// completeMFAChallenge verifies a pending-MFA token and code, returning the user on success.
+// Wrong codes count towards the same throttle as wrong passwords.
+func completeMFAChallenge(r *http.Request, mfaToken, code string) (*models.User, error) {
+	userID, err := utils.VerifyChallengeJWT(mfaToken, "mfa")
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	ip := utils.ClientIP(r)
+	if err := utils.CheckLoginThrottle(user.Username, ip); err != nil {
+		recordLoginEvent(r, utils.AuthMethodMFA, utils.AuthOutcomeFailure, "throttled", &user, "")
+		return nil, err
+	}
+
+	if err := utils.VerifyMFACode(&user, code); err != nil {
+		utils.RecordLoginFailure(user.Username, ip)
+		recordLoginEvent(r, utils.AuthMethodMFA, utils.AuthOutcomeFailure, "invalid_mfa_code", &user, "")
+		return nil, err
+	}
+
+	// A challenge completes one login; replaying it must fail
+	if err := utils.ConsumeChallengeJWT(mfaToken, "mfa"); err != nil {
+		recordLoginEvent(r, utils.AuthMethodMFA, utils.AuthOutcomeFailure, "mfa_challenge_reused", &user, "")
+		return nil, err
+	}
+
+	utils.RecordLoginSuccess(user.Username)
+	return &user, nil
+}
+
+// MFAVerifyHandler completes a JSON login with a TOTP or recovery code
+func MFAVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		utils.SendError(w, "mfaToken and code are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := completeMFAChallenge(r, req.MFAToken, req.Code)
+	if err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			utils.SendTooManyAttempts(w, throttleErr)
+			return
+		}
+		utils.SendError(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	sendLoginResult(w, r, user, true)
+}
+
+// MFALoginFormHandler completes a form login with a TOTP or recovery code
+func MFALoginFormHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := utils.GetMFACookie(r)
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=mfa_expired", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/mfa?error=invalid_form", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := completeMFAChallenge(r, cookie.Value, r.FormValue("code"))
+	if err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			utils.SendTooManyAttempts(w, throttleErr)
+			return
+		}
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/mfa?error=invalid_code", http.StatusTemporaryRedirect)
+		return
+	}
+
+	utils.ClearMFACookie(w)
+	redirectLoginResult(w, r, user, true)
+}
+
+// EnrollTOTPHandler starts TOTP enrollment for the current user
+func EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.SendError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, recoveryCodes, err := utils.StartTOTPEnrollment(user)
+	if err != nil {
+		utils.SendError(w, "Failed to start enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, TOTPEnrollResponse{
+		Secret:        secret,
+		OTPAuthURI:    utils.BuildOTPAuthURI(config.TOTP_ISSUER, user.Username, secret),
+		RecoveryCodes: recoveryCodes,
+	}, http.StatusOK)
+}
+
+// ConfirmTOTPHandler activates TOTP once the user submits a valid code
+func ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	var req TOTPConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.SendError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	if err := utils.ConfirmTOTPEnrollment(user, req.Code); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Two-factor authentication enabled",
+	}, http.StatusOK)
+}
+
+// ResetUserMFAHandler lets an admin clear a user's TOTP enrollment (/users/{id}/mfa)
+func ResetUserMFAHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Authorize(admin, policy.UserManage, policy.Resource{}) != nil {
+		utils.SendError(w, "Only admins can reset two-factor authentication", http.StatusForbidden)
+		return
+	}
+
+	// Extract user ID from URL path (/users/5/mfa)
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/mfa")
+	userID, err := strconv.ParseUint(path, 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if db.DB.First(&user, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := policy.CanManageUser(admin, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := utils.ResetTOTPEnrollment(user.ID); err != nil {
+		utils.SendError(w, "Failed to reset two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Two-factor authentication reset",
+		"user":    user.Username,
+	}, http.StatusOK)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-auth/config"
+	"go-auth/utils"
+)
+
+// OIDCLoginHandler starts single sign-on by redirecting to the IdP (authorization code + PKCE)
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !config.OIDC_ENABLED {
+		http.NotFound(w, r)
+		return
+	}
+
+	provider, err := utils.GetOIDCProvider()
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_unavailable", http.StatusTemporaryRedirect)
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier, err := utils.GeneratePKCEVerifier()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	// Keep state, nonce and verifier in a signed cookie until the IdP redirects back
+	stateToken, err := utils.CreateOIDCState(state, nonce, verifier)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	utils.SetOIDCStateCookie(w, stateToken)
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// OIDCCallbackHandler completes single sign-on and issues the session cookie
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !config.OIDC_ENABLED {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_denied", http.StatusTemporaryRedirect)
+		return
+	}
+
+	cookie, err := utils.GetOIDCStateCookie(r)
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_expired", http.StatusTemporaryRedirect)
+		return
+	}
+	utils.ClearOIDCStateCookie(w)
+
+	nonce, verifier, err := utils.CheckOIDCState(cookie.Value, query.Get("state"))
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_state_mismatch", http.StatusTemporaryRedirect)
+		return
+	}
+
+	provider, err := utils.GetOIDCProvider()
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_unavailable", http.StatusTemporaryRedirect)
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(query.Get("code"), verifier)
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_invalid_token", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := utils.ProvisionOIDCUser(claims)
+	if err != nil {
+		username, _ := claims[config.OIDC_USERNAME_CLAIM].(string)
+		recordLoginEvent(r, utils.LoginMethodSSO, utils.AuthOutcomeFailure, "user_rejected", nil, username)
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=sso_user_rejected", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err := checkLoginSchedule(r, user, utils.LoginMethodSSO); err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=outside_schedule", http.StatusTemporaryRedirect)
+		return
+	}
+
+	redirectNewSession(w, r, user, utils.LoginMethodSSO)
+}
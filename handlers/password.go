@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+// Request/Response structures
+type PasswordChangeRequiredResponse struct {
+	PasswordChangeRequired bool   `json:"passwordChangeRequired"`
+	ChangeToken            string `json:"changeToken"`
+}
+
+type RequiredPasswordChangeRequest struct {
+	ChangeToken string `json:"changeToken"`
+	NewPassword string `json:"newPassword"`
+}
+
+// createPasswordChangeChallenge issues the token a user with an expired password logs in with
+func createPasswordChangeChallenge(user models.User) (string, error) {
+	expiresAt := time.Now().Add(time.Minute * config.PASSWORD_CHANGE_TOKEN_EXPIRY_MINUTES)
+	return utils.CreateChallengeJWT(user.ID, "password_change", expiresAt)
+}
+
+// completePasswordChange verifies a password-change token and stores the new password
+func completePasswordChange(changeToken, newPassword string) (*models.User, error) {
+	userID, err := utils.VerifyChallengeJWT(changeToken, "password_change")
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := utils.ValidatePasswordPolicy(user.Username, newPassword); err != nil {
+		return nil, err
+	}
+	if err := utils.CheckPasswordHistory(&user, newPassword); err != nil {
+		return nil, err
+	}
+
+	// The token is good for one password change only
+	if err := utils.ConsumeChallengeJWT(changeToken, "password_change"); err != nil {
+		return nil, err
+	}
+
+	if err := utils.SetUserPassword(&user, newPassword, false); err != nil {
+		return nil, err
+	}
+
+	// Any session opened with the old password is no longer trusted
+	utils.RevokeUserSessions(user.ID)
+
+	return &user, nil
+}
+
+// RequiredPasswordChangeHandler completes a JSON login that returned passwordChangeRequired
+func RequiredPasswordChangeHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RequiredPasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ChangeToken == "" || req.NewPassword == "" {
+		utils.SendError(w, "changeToken and newPassword are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := completePasswordChange(req.ChangeToken, req.NewPassword)
+	if err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sendNewSession(w, r, user, utils.LoginMethodPassword)
+}
+
+// RequiredPasswordChangeFormHandler completes a form login that was sent to the change-password page
+func RequiredPasswordChangeFormHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := utils.GetPasswordChangeCookie(r)
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=password_change_expired", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/change-password?error=invalid_form", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := completePasswordChange(cookie.Value, r.FormValue("newPassword"))
+	if err != nil {
+		http.Redirect(w, r, config.FRONTEND_URL+"/login/change-password?error=password_rejected", http.StatusTemporaryRedirect)
+		return
+	}
+
+	utils.ClearPasswordChangeCookie(w)
+	redirectNewSession(w, r, user, utils.LoginMethodPassword)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type RedeemPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ChangeOwnPasswordHandler lets the current user change their password (/me/password)
+func ChangeOwnPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		utils.SendError(w, "currentPassword and newPassword are required", http.StatusBadRequest)
+		return
+	}
+
+	if user.AuthProvider != "" && user.AuthProvider != "local" {
+		utils.SendError(w, "Password is managed by your identity provider", http.StatusBadRequest)
+		return
+	}
+
+	// Re-check the current password; wrong guesses count towards the login throttle
+	if _, err := utils.CheckCredentials(r, user.Username, req.CurrentPassword); err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			utils.SendTooManyAttempts(w, throttleErr)
+			return
+		}
+		utils.SendError(w, "Current password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	if err := utils.ValidatePasswordPolicy(user.Username, req.NewPassword); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := utils.CheckPasswordHistory(user, req.NewPassword); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.SetUserPassword(user, req.NewPassword, false); err != nil {
+		utils.SendError(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	// Sign out everywhere else, then give this browser a fresh session
+	utils.RevokeUserSessions(user.ID)
+	expiresAt, err := utils.IssueSession(w, r, *user, utils.LoginMethodPassword)
+	if err != nil {
+		utils.SendError(w, "Password changed, but failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Password changed successfully",
+		"expires": expiresAt.Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+// IssuePasswordResetHandler lets an admin create a reset link for a user (/users/{id}/password-reset)
+func IssuePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Authorize(admin, policy.UserManage, policy.Resource{}) != nil {
+		utils.SendError(w, "Only admins can issue password resets", http.StatusForbidden)
+		return
+	}
+
+	// Extract user ID from URL path (/users/5/password-reset)
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/password-reset")
+	userID, err := strconv.ParseUint(path, 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if db.DB.First(&user, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := policy.CanManageUser(admin, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if user.AuthProvider != "" && user.AuthProvider != "local" {
+		utils.SendError(w, "Password is managed by the user's identity provider", http.StatusBadRequest)
+		return
+	}
+
+	resetToken, expiresAt, err := utils.CreatePasswordResetToken(user.ID, admin.Username)
+	if err != nil {
+		utils.SendError(w, "Failed to create reset token", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"resetToken": resetToken,
+		"resetLink":  fmt.Sprintf("%s/reset-password?token=%s", config.FRONTEND_URL, resetToken),
+		"expiresAt":  expiresAt,
+		"user":       user.Username,
+	}, http.StatusOK)
+}
+
+// RedeemPasswordResetHandler sets a new password using an admin-issued reset token
+func RedeemPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RedeemPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		utils.SendError(w, "token and newPassword are required", http.StatusBadRequest)
+		return
+	}
+
+	record, err := utils.GetValidPasswordResetToken(req.Token)
+	if err != nil {
+		utils.SendError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if db.DB.First(&user, record.UserID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := utils.ValidatePasswordPolicy(user.Username, req.NewPassword); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := utils.CheckPasswordHistory(&user, req.NewPassword); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Claim the token before changing anything so it can only be used once
+	if err := utils.ConsumePasswordResetToken(record.ID); err != nil {
+		utils.SendError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := utils.SetUserPassword(&user, req.NewPassword, false); err != nil {
+		utils.SendError(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	// Whoever held the old credentials loses access
+	utils.RevokeUserSessions(user.ID)
+	utils.RevokeUserLoginTokens(user.ID)
+	utils.RecordLoginSuccess(user.Username)
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Password reset successfully",
+	}, http.StatusOK)
+}
+
+// LegacyPasswordHashesHandler reports how many users still have a legacy
+// password hash, to tell when the upgrade is complete (/password-hashes/legacy)
+func LegacyPasswordHashesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.UserManage, "Only admins can view password hash status") {
+		return
+	}
+
+	count, err := utils.CountLegacyPasswordHashes()
+	if err != nil {
+		utils.SendError(w, "Failed to count legacy password hashes", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"legacyPasswordHashes": count,
+	}, http.StatusOK)
+}
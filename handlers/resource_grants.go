@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type ResourceGrantRequest struct {
+	GranteeType string     `json:"granteeType"`
+	GranteeID   string     `json:"granteeId"`
+	Access      string     `json:"access"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// ViewGroupGrantsHandler lists (GET) or adds (POST) the grants sharing a view
+// group (/view-groups/{id}/grants) and revokes one (DELETE .../grants/{grantId})
+func ViewGroupGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	// Split /view-groups/abc/grants[/3]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/view-groups/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] != "grants" {
+		utils.SendError(w, "Invalid view group ID", http.StatusBadRequest)
+		return
+	}
+
+	viewGroup, err := utils.GetViewGroupByID(parts[0])
+	if err != nil {
+		utils.SendError(w, "View group not found", http.StatusNotFound)
+		return
+	}
+	if err := utils.CanShareViewGroup(user, viewGroup); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	handleResourceGrants(w, r, user, policy.ResourceViewGroup, viewGroup.ID, parts[2:])
+}
+
+// CustomMapGrantsHandler lists (GET) or adds (POST) the grants sharing a custom
+// map (/custom-maps/{id}/grants) and revokes one (DELETE .../grants/{grantId})
+func CustomMapGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	// Split /custom-maps/5/grants[/3]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/custom-maps/"), "/")
+	mapID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || len(parts) < 2 || parts[1] != "grants" {
+		utils.SendError(w, "Invalid map ID", http.StatusBadRequest)
+		return
+	}
+
+	var customMap models.CustomMap
+	if err := db.DB.First(&customMap, mapID).Error; err != nil {
+		utils.SendError(w, "Map not found", http.StatusNotFound)
+		return
+	}
+	if err := utils.CanShareCustomMap(user, &customMap); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	handleResourceGrants(w, r, user, policy.ResourceCustomMap, strconv.FormatUint(mapID, 10), parts[2:])
+}
+
+// handleResourceGrants serves the grants of a resource the caller may share.
+// rest holds the path segments after /grants.
+func handleResourceGrants(w http.ResponseWriter, r *http.Request, user *models.User, resourceType, resourceID string, rest []string) {
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		grants, err := utils.GetResourceGrants(resourceType, resourceID)
+		if err != nil {
+			utils.SendError(w, "Failed to fetch grants", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, grants, http.StatusOK)
+
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		var req ResourceGrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		grant := models.ResourceGrant{
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			GranteeType:  req.GranteeType,
+			GranteeID:    strings.TrimSpace(req.GranteeID),
+			Access:       req.Access,
+			ExpiresAt:    req.ExpiresAt,
+			GrantedBy:    user.Username,
+		}
+		if grant.Access == "" {
+			grant.Access = policy.GrantRead
+		}
+		if err := utils.ValidateResourceGrant(&grant); err != nil {
+			utils.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.CreateResourceGrant(&grant); err != nil {
+			utils.SendError(w, "Failed to save grant", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, grant, http.StatusCreated)
+
+	case len(rest) == 1 && r.Method == http.MethodDelete:
+		grantID, err := strconv.ParseUint(rest[0], 10, 32)
+		if err != nil {
+			utils.SendError(w, "Invalid grant ID", http.StatusBadRequest)
+			return
+		}
+		found, err := utils.RevokeResourceGrant(resourceType, resourceID, uint(grantID))
+		if err != nil {
+			utils.SendError(w, "Failed to revoke grant", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			utils.SendError(w, "Grant not found", http.StatusNotFound)
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Grant revoked",
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
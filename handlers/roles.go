@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type RoleRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Permissions []models.RolePermission `json:"permissions"`
+}
+
+// roleErrorStatus maps role store errors to HTTP statuses
+var roleErrorStatus = map[error]int{
+	policy.ErrRoleNotFound: http.StatusNotFound,
+	policy.ErrRoleExists:   http.StatusConflict,
+	policy.ErrRoleInUse:    http.StatusConflict,
+	policy.ErrRoleBuiltIn:  http.StatusForbidden,
+	policy.ErrRoleLocked:   http.StatusForbidden,
+}
+
+// sendRoleError responds with the status for a role store error
+func sendRoleError(w http.ResponseWriter, err error, fallback string) {
+	for roleErr, status := range roleErrorStatus {
+		if errors.Is(err, roleErr) {
+			utils.SendError(w, err.Error(), status)
+			return
+		}
+	}
+	utils.SendError(w, fallback, http.StatusInternalServerError)
+}
+
+// decodeRoleRequest reads a role body and validates its permissions
+func decodeRoleRequest(w http.ResponseWriter, r *http.Request) (*RoleRequest, bool) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := policy.ValidatePermissions(req.Permissions); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// RolesHandler lists (GET) or creates (POST) roles (/roles)
+func RolesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if !requirePermission(w, r, policy.RoleManage, "Only admins can manage roles") {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		roles, err := policy.ListRoles()
+		if err != nil {
+			utils.SendError(w, "Failed to fetch roles", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, roles, http.StatusOK)
+
+	case http.MethodPost:
+		req, ok := decodeRoleRequest(w, r)
+		if !ok {
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 100 {
+			utils.SendError(w, "name is required (max 100 characters)", http.StatusBadRequest)
+			return
+		}
+
+		role, err := policy.CreateRole(req.Name, req.Description, req.Permissions)
+		if err != nil {
+			sendRoleError(w, err, "Failed to create role")
+			return
+		}
+		utils.SendJSON(w, role, http.StatusCreated)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RoleHandler reads (GET), replaces the permissions of (PUT) or deletes
+// (DELETE) one role (/roles/{name})
+func RoleHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if !requirePermission(w, r, policy.RoleManage, "Only admins can manage roles") {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/roles/")
+	if name == "" {
+		utils.SendError(w, "Role name is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		role, err := policy.GetRole(name)
+		if err != nil {
+			sendRoleError(w, err, "Failed to fetch role")
+			return
+		}
+		utils.SendJSON(w, role, http.StatusOK)
+
+	case http.MethodPut:
+		req, ok := decodeRoleRequest(w, r)
+		if !ok {
+			return
+		}
+		role, err := policy.UpdateRole(name, req.Description, req.Permissions)
+		if err != nil {
+			sendRoleError(w, err, "Failed to update role")
+			return
+		}
+		utils.SendJSON(w, role, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := policy.DeleteRole(name); err != nil {
+			sendRoleError(w, err, "Failed to delete role")
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Role deleted",
+			"role":    name,
+		}, http.StatusOK)
+
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PermissionsHandler lists the permissions and scopes roles can be granted (/permissions)
+func PermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.RoleManage, "Only admins can manage roles") {
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"permissions": append([]string{policy.AllPermissions}, policy.Permissions...),
+		"scopes":      []string{policy.ScopeAll, policy.ScopeArea},
+	}, http.StatusOK)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+// MySessionsHandler lists the current user's active sessions (/me/sessions)
+func MySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	sendUserSessions(w, user.ID, user.Auth.SessionID)
+}
+
+// MySessionHandler signs the current user out of one session (/me/sessions/{id})
+func MySessionHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	revokeUserSession(w, user.ID, strings.TrimPrefix(r.URL.Path, "/me/sessions/"))
+}
+
+// UserSessionsHandler lets an admin list (/users/{id}/sessions) or revoke
+// (/users/{id}/sessions/{sessionId}) another user's sessions
+func UserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	admin, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	if policy.Authorize(admin, policy.UserManage, policy.Resource{}) != nil {
+		utils.SendError(w, "Only admins can manage other users' sessions", http.StatusForbidden)
+		return
+	}
+
+	// Split /users/5/sessions[/12]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/users/"), "/")
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || len(parts) < 2 || parts[1] != "sessions" {
+		utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if db.DB.First(&user, userID).Error != nil {
+		utils.SendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := policy.CanManageUser(admin, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		sendUserSessions(w, user.ID, admin.Auth.SessionID)
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		revokeUserSession(w, user.ID, parts[2])
+	default:
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sendUserSessions responds with a user's active sessions, flagging the caller's own
+func sendUserSessions(w http.ResponseWriter, userID, currentSessionID uint) {
+	sessions, err := utils.GetActiveUserSessions(userID)
+	if err != nil {
+		utils.SendError(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	utils.SendJSON(w, sessions, http.StatusOK)
+}
+
+// revokeUserSession revokes one of a user's sessions by its ID
+func revokeUserSession(w http.ResponseWriter, userID uint, rawSessionID string) {
+	sessionID, err := strconv.ParseUint(rawSessionID, 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var session models.Session
+	if db.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error != nil {
+		utils.SendError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := utils.RevokeSessionFamily(session.ID); err != nil {
+		utils.SendError(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Session revoked",
+	}, http.StatusOK)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go-auth/config"
+	"go-auth/policy"
+	"go-auth/utils"
+)
+
+type RotateSigningKeyRequest struct {
+	Alg string `json:"alg,omitempty"` // HS256, RS256 or EdDSA; defaults to JWT_SIGNING_ALG
+}
+
+// JWKSHandler publishes the public keys other services use to verify our tokens (/.well-known/jwks.json)
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jwks, err := utils.PublicJWKS()
+	if err != nil {
+		utils.SendError(w, "Failed to load signing keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.SendJSON(w, jwks, http.StatusOK)
+}
+
+// SigningKeysHandler lists the key ring for admins (/signing-keys)
+func SigningKeysHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		utils.SendError(w, "Only GET method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.KeyManage, "Only admins can manage signing keys") {
+		return
+	}
+
+	keys, err := utils.ListSigningKeys()
+	if err != nil {
+		utils.SendError(w, "Failed to fetch signing keys", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, keys, http.StatusOK)
+}
+
+// RotateSigningKeyHandler makes a freshly generated key the signing key (/signing-keys/rotate).
+// Tokens signed by the previous key stay valid until it is retired.
+func RotateSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		utils.SendError(w, "Only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.KeyManage, "Only admins can manage signing keys") {
+		return
+	}
+
+	// Body is optional
+	var req RotateSigningKeyRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Alg == "" {
+		req.Alg = config.JWT_SIGNING_ALG
+	}
+	if req.Alg != "HS256" && req.Alg != "RS256" && req.Alg != "EdDSA" {
+		utils.SendError(w, "alg must be HS256, RS256 or EdDSA", http.StatusBadRequest)
+		return
+	}
+
+	key, err := utils.RotateSigningKey(req.Alg)
+	if err != nil {
+		utils.SendError(w, "Failed to rotate signing key", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, key, http.StatusCreated)
+}
+
+// RetireSigningKeyHandler stops a rotated-out key from verifying tokens (/signing-keys/{kid})
+func RetireSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requirePermission(w, r, policy.KeyManage, "Only admins can manage signing keys") {
+		return
+	}
+
+	kid := strings.TrimPrefix(r.URL.Path, "/signing-keys/")
+	if err := utils.RetireSigningKey(kid); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Signing key retired",
+		"kid":     kid,
+	}, http.StatusOK)
+}
+
+// requirePermission sends an error and returns false unless the caller's role
+// grants action everywhere
+func requirePermission(w http.ResponseWriter, r *http.Request, action, message string) bool {
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return false
+	}
+	if policy.Scope(user, action) != policy.ScopeAll {
+		utils.SendError(w, message, http.StatusForbidden)
+		return false
+	}
+	return true
+}
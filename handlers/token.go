@@ -2,27 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-auth/config"
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 	"go-auth/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Request/Response structures
 type GenerateTokenRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username      string   `json:"username,omitempty"` // with Password, instead of a session
+	Password      string   `json:"password,omitempty"`
+	MFACode       string   `json:"mfaCode,omitempty"` // required with credentials when two-factor login is on
+	Label         string   `json:"label,omitempty"`
+	ExpiresInDays int      `json:"expiresInDays,omitempty"` // defaults to LOGIN_TOKEN_DEFAULT_EXPIRY_DAYS
+	MaxUses       int      `json:"maxUses,omitempty"`       // 0 = unlimited
+	ReadOnly      bool     `json:"readOnly,omitempty"`
+	ViewGroupIDs  []string `json:"viewGroupIds,omitempty"`
 }
 
 type GenerateTokenResponse struct {
-	Success   bool   `json:"success"`
-	Token     string `json:"token"`
-	LoginLink string `json:"loginLink"`
+	Success   bool         `json:"success"`
+	Token     string       `json:"token"`
+	LoginLink string       `json:"loginLink"`
+	Record    models.Token `json:"record"`
 }
 
 type VerifyTokenRequest struct {
@@ -30,15 +40,54 @@ type VerifyTokenRequest struct {
 }
 
 type VerifyTokenResponse struct {
-	Success  bool   `json:"success"`
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	GroupId  int    `json:"groupId"`
-	AreaName string `json:"areaName"`
-	Role     string `json:"role"`
+	Success      bool     `json:"success"`
+	ID           uint     `json:"id"`
+	Username     string   `json:"username"`
+	GroupId      int      `json:"groupId"`
+	AreaName     string   `json:"areaName"`
+	Role         string   `json:"role"`
+	ReadOnly     bool     `json:"readOnly"`
+	ViewGroupIDs []string `json:"viewGroupIds"`
 }
 
-// GenerateTokenHandler generates a login token
+// loginTokenErrorMessages maps ConsumeLoginToken errors to API error messages
+var loginTokenErrorMessages = map[error]string{
+	utils.ErrLoginTokenInvalid:   "Invalid token",
+	utils.ErrLoginTokenRevoked:   "Token revoked",
+	utils.ErrLoginTokenExpired:   "Token expired",
+	utils.ErrLoginTokenSignature: "Invalid token signature",
+	utils.ErrLoginTokenUsedUp:    "Token has reached its maximum number of uses",
+}
+
+// loginTokenRedirectErrors maps ConsumeLoginToken errors to the frontend login error codes
+var loginTokenRedirectErrors = map[error]string{
+	utils.ErrLoginTokenInvalid:   "invalid_token",
+	utils.ErrLoginTokenRevoked:   "token_revoked",
+	utils.ErrLoginTokenExpired:   "token_expired",
+	utils.ErrLoginTokenSignature: "invalid_signature",
+	utils.ErrLoginTokenUsedUp:    "token_used_up",
+}
+
+// recordLoginTokenEvent logs the use of a login link and why it failed, if it did
+func recordLoginTokenEvent(r *http.Request, event string, tokenRecord *models.Token, err error) {
+	authEvent := models.AuthEvent{
+		Event:   event,
+		Method:  utils.LoginMethodLoginLink,
+		Outcome: utils.AuthOutcomeSuccess,
+	}
+	if err != nil {
+		authEvent.Outcome = utils.AuthOutcomeFailure
+		authEvent.Reason = err.Error()
+	}
+	if tokenRecord != nil {
+		authEvent.Username = tokenRecord.Username
+		authEvent.Reason = strings.TrimSpace(fmt.Sprintf("%s (token %d)", authEvent.Reason, tokenRecord.ID))
+	}
+	utils.RecordAuthEvent(r, authEvent, nil)
+}
+
+// GenerateTokenHandler generates a login link for the current user, or for the
+// user whose username and password (and two-factor code) are in the request
 func GenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	utils.SetCORSHeaders(w)
 
@@ -57,49 +106,70 @@ func GenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if err := utils.ValidateLoginRequest(req.Username, req.Password); err != nil {
-		utils.SendError(w, err.Error(), http.StatusBadRequest)
-		return
+	var user *models.User
+	if req.Username != "" || req.Password != "" {
+		var ok bool
+		if user, ok = loginLinkCredentialsUser(w, r, req); !ok {
+			return
+		}
+	} else {
+		var err error
+		if user, err = utils.GetUserFromSession(r); err != nil {
+			utils.SendAuthError(w, err)
+			return
+		}
+		if policy.FromLoginLink(user) {
+			utils.SendError(w, "Login links cannot be created from a login-link session", http.StatusForbidden)
+			return
+		}
 	}
 
-	// Find user in database
-	var user models.User
-	result := db.DB.Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
-		utils.SendError(w, "Invalid credentials", http.StatusUnauthorized)
+	// Validate link options
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = config.LOGIN_TOKEN_DEFAULT_EXPIRY_DAYS
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > config.LOGIN_TOKEN_MAX_EXPIRY_DAYS {
+		utils.SendError(w, fmt.Sprintf("expiresInDays must be between 1 and %d", config.LOGIN_TOKEN_MAX_EXPIRY_DAYS), http.StatusBadRequest)
 		return
 	}
-
-	// Verify password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
-		utils.SendError(w, "Invalid credentials", http.StatusUnauthorized)
+	if req.MaxUses < 0 {
+		utils.SendError(w, "maxUses cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if len(req.Label) > 255 {
+		utils.SendError(w, "label must be at most 255 characters", http.StatusBadRequest)
 		return
 	}
+	for _, id := range req.ViewGroupIDs {
+		viewGroup, err := utils.GetViewGroupByID(id)
+		if err != nil {
+			utils.SendError(w, fmt.Sprintf("View group %s not found", id), http.StatusBadRequest)
+			return
+		}
+		if err := utils.CanViewViewGroup(user, viewGroup); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
 
 	// Generate JWT token
-	expiresAt := time.Now().Add(time.Hour * 24 * 365 * config.TOKEN_EXPIRY_YEARS)
+	expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
 
-	jwtToken, err := utils.CreateTokenJWT(user, expiresAt)
+	jwtToken, err := utils.CreateTokenJWT(*user, expiresAt)
 	if err != nil {
 		utils.SendError(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	// Store token in database
-	tokenRecord := models.Token{
-		UserID:    user.ID,
-		Username:  user.Username,
-		GroupId:   user.GroupId,
-		AreaName:  user.AreaName,
-		Role:      user.Role,
-		Token:     jwtToken,
-		IsUsed:    false,
-		ExpiresAt: expiresAt,
-	}
-
-	if db.DB.Create(&tokenRecord).Error != nil {
+	// Store only the token hash in the database
+	tokenRecord, err := utils.CreateLoginToken(user, jwtToken, models.Token{
+		Label:        req.Label,
+		MaxUses:      req.MaxUses,
+		ReadOnly:     req.ReadOnly,
+		ViewGroupIDs: req.ViewGroupIDs,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
 		utils.SendError(w, "Failed to store token", http.StatusInternalServerError)
 		return
 	}
@@ -109,6 +179,7 @@ func GenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		Success:   true,
 		Token:     jwtToken,
 		LoginLink: fmt.Sprintf("%s/verify?token=%s", config.BACKEND_URL, jwtToken),
+		Record:    *tokenRecord,
 	}
 
 	utils.SendJSON(w, response, http.StatusOK)
@@ -139,43 +210,28 @@ func VerifyTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find token in database
-	var tokenRecord models.Token
-	result := db.DB.Where("token = ?", req.Token).First(&tokenRecord)
-	if result.Error != nil {
-		utils.SendError(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	// Check if token expired
-	if time.Now().After(tokenRecord.ExpiresAt) {
-		utils.SendError(w, "Token expired", http.StatusUnauthorized)
-		return
-	}
-
-	// Verify JWT signature
-	valid, err := utils.VerifyTokenJWT(req.Token)
-	if err != nil || !valid {
-		utils.SendError(w, "Invalid token signature", http.StatusUnauthorized)
+	// Check the token and count one use
+	tokenRecord, err := utils.ConsumeLoginToken(req.Token)
+	recordLoginTokenEvent(r, utils.AuthEventTokenVerify, tokenRecord, err)
+	if err != nil {
+		message, ok := loginTokenErrorMessages[err]
+		if !ok {
+			message = "Invalid token"
+		}
+		utils.SendError(w, message, http.StatusUnauthorized)
 		return
 	}
 
-	// Track first use
-	if !tokenRecord.IsUsed {
-		now := time.Now()
-		tokenRecord.IsUsed = true
-		tokenRecord.UsedAt = &now
-		db.DB.Save(&tokenRecord)
-	}
-
 	// Return user data
 	response := VerifyTokenResponse{
-		Success:  true,
-		ID:       tokenRecord.UserID,
-		Username: tokenRecord.Username,
-		GroupId:  tokenRecord.GroupId,
-		AreaName: tokenRecord.AreaName,
-		Role:     tokenRecord.Role,
+		Success:      true,
+		ID:           tokenRecord.UserID,
+		Username:     tokenRecord.Username,
+		GroupId:      tokenRecord.GroupId,
+		AreaName:     tokenRecord.AreaName,
+		Role:         tokenRecord.Role,
+		ReadOnly:     tokenRecord.ReadOnly,
+		ViewGroupIDs: tokenRecord.ViewGroupIDs,
 	}
 
 	utils.SendJSON(w, response, http.StatusOK)
@@ -195,45 +251,195 @@ func VerifyAndLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find token in database
-	var tokenRecord models.Token
-	result := db.DB.Where("token = ?", token).First(&tokenRecord)
-	if result.Error != nil {
+	// Outside the user's access schedule the link is refused without using it up
+	if owner, err := utils.LoginTokenUser(token); err == nil {
+		if err := checkLoginSchedule(r, owner, utils.LoginMethodLoginLink); err != nil {
+			http.Redirect(w, r, config.FRONTEND_URL+"/login?error=outside_schedule", http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	// Check the token and count one use
+	tokenRecord, err := utils.ConsumeLoginToken(token)
+	recordLoginTokenEvent(r, utils.AuthEventLogin, tokenRecord, err)
+	if err != nil {
+		code, ok := loginTokenRedirectErrors[err]
+		if !ok {
+			code = "invalid_token"
+		}
+		http.Redirect(w, r, config.FRONTEND_URL+"/login?error="+code, http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Load the user the token was issued for
+	var user models.User
+	if db.DB.First(&user, tokenRecord.UserID).Error != nil {
 		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=invalid_token", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Check if token expired
-	if time.Now().After(tokenRecord.ExpiresAt) {
-		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=token_expired", http.StatusTemporaryRedirect)
+	// Start session (with the link's restrictions) and set access/refresh cookies
+	if _, err := utils.IssueLoginTokenSession(w, r, user, tokenRecord); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
-	// Verify JWT signature
-	valid, err := utils.VerifyTokenJWT(token)
-	if err != nil || !valid {
-		http.Redirect(w, r, config.FRONTEND_URL+"/login?error=invalid_signature", http.StatusTemporaryRedirect)
+	// Redirect to home page
+	http.Redirect(w, r, config.FRONTEND_URL+"/", http.StatusTemporaryRedirect)
+}
+
+// loginLinkCredentialsUser checks the credentials in a link request the way a
+// login does: throttled, through every authenticator, and with the two-factor
+// code and any pending password change enforced, so a password alone never
+// yields a link that skips them
+func loginLinkCredentialsUser(w http.ResponseWriter, r *http.Request, req GenerateTokenRequest) (*models.User, bool) {
+	if err := utils.ValidateLoginRequest(req.Username, req.Password); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Verify credentials (throttled per username and IP)
+	user, err := utils.CheckCredentials(r, req.Username, req.Password)
+	if err != nil {
+		var throttleErr *utils.ThrottleError
+		if errors.As(err, &throttleErr) {
+			recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "throttled", nil, req.Username)
+			utils.SendTooManyAttempts(w, throttleErr)
+			return nil, false
+		}
+		recordLoginEvent(r, utils.LoginMethodPassword, utils.AuthOutcomeFailure, "invalid_credentials", nil, req.Username)
+		utils.SendError(w, "Invalid credentials", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if user.TOTPEnabled {
+		if req.MFACode == "" {
+			utils.SendError(w, "mfaCode is required for this account", http.StatusUnauthorized)
+			return nil, false
+		}
+		if err := utils.VerifyMFACode(user, req.MFACode); err != nil {
+			utils.RecordLoginFailure(user.Username, utils.ClientIP(r))
+			recordLoginEvent(r, utils.AuthMethodMFA, utils.AuthOutcomeFailure, "invalid_mfa_code", user, "")
+			utils.SendError(w, "Invalid two-factor code", http.StatusUnauthorized)
+			return nil, false
+		}
+	}
+
+	if utils.PasswordChangeRequired(user) {
+		utils.SendError(w, "Password change required", http.StatusForbidden)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// LoginTokensHandler lists (GET) or revokes all (DELETE) of a user's login links (/tokens).
+// Admins may pass ?userId= to act on another user.
+func LoginTokensHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
 		return
 	}
 
-	// Track first use
-	if !tokenRecord.IsUsed {
-		now := time.Now()
-		tokenRecord.IsUsed = true
-		tokenRecord.UsedAt = &now
-		db.DB.Save(&tokenRecord)
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Create NextAuth JWT token
-	nextAuthToken, err := utils.CreateNextAuthJWT(tokenRecord)
+	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		utils.SendAuthError(w, err)
 		return
 	}
 
-	// Set NextAuth session cookie
-	utils.SetSessionCookie(w, nextAuthToken)
+	userID := user.ID
+	if param := r.URL.Query().Get("userId"); param != "" {
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			utils.SendError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		var owner models.User
+		if db.DB.First(&owner, id).Error != nil {
+			utils.SendError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if policy.Authorize(user, policy.TokenManage, policy.OwnedBy(&owner)) != nil {
+			utils.SendError(w, "Only admins can manage other users' tokens", http.StatusForbidden)
+			return
+		}
+		userID = owner.ID
+	}
 
-	// Redirect to home page
-	http.Redirect(w, r, config.FRONTEND_URL+"/", http.StatusTemporaryRedirect)
-}
\ No newline at end of file
+	if r.Method == http.MethodDelete {
+		if err := utils.RevokeUserLoginTokens(userID); err != nil {
+			utils.SendError(w, "Failed to revoke tokens", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSON(w, map[string]interface{}{
+			"message": "Tokens revoked",
+		}, http.StatusOK)
+		return
+	}
+
+	tokens, err := utils.GetUserLoginTokens(userID)
+	if err != nil {
+		utils.SendError(w, "Failed to fetch tokens", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, tokens, http.StatusOK)
+}
+
+// RevokeLoginTokenHandler revokes a single login link (/tokens/{id})
+func RevokeLoginTokenHandler(w http.ResponseWriter, r *http.Request) {
+	utils.SetCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		utils.SendError(w, "Only DELETE method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/tokens/"), 10, 32)
+	if err != nil {
+		utils.SendError(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	var tokenRecord models.Token
+	if db.DB.First(&tokenRecord, tokenID).Error != nil {
+		utils.SendError(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	// A link whose owner is gone counts as in the area it was issued for
+	var owner models.User
+	if db.DB.First(&owner, tokenRecord.UserID).Error != nil {
+		owner.ID = tokenRecord.UserID
+		owner.GroupId = tokenRecord.GroupId
+	}
+	if policy.Authorize(user, policy.TokenManage, policy.OwnedBy(&owner)) != nil {
+		utils.SendError(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	if err := utils.RevokeLoginToken(tokenRecord.ID); err != nil {
+		utils.SendError(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSON(w, map[string]interface{}{
+		"message": "Token revoked",
+	}, http.StatusOK)
+}
@@ -29,7 +29,7 @@ func GetDefaultViewHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -53,7 +53,7 @@ func SetDefaultViewHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -73,7 +73,7 @@ func SetDefaultViewHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Check if user has access to this view
-		if user.Role != "admin" && user.GroupId != viewGroup.GroupID {
+		if utils.CanViewViewGroup(user, viewGroup) != nil {
 			utils.SendError(w, "You don't have access to this view", http.StatusForbidden)
 			return
 		}
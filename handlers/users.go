@@ -5,12 +5,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"fmt"
+	"time"
 
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 	"go-auth/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Request structures
@@ -18,15 +18,15 @@ type CreateUserRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	GroupId  int    `json:"groupId"`
-	AreaName string `json:"areaName"`
 	Role     string `json:"role"`
+	MustChangePassword bool `json:"mustChangePassword"`
 }
 
 type UpdateUserRequest struct {
 	Password string `json:"password,omitempty"`
 	GroupId  int    `json:"groupId,omitempty"`
-	AreaName string `json:"areaName,omitempty"`
 	Role     string `json:"role,omitempty"`
+	MustChangePassword *bool `json:"mustChangePassword,omitempty"`
 }
 
 // CreateUserHandler creates a new user
@@ -42,6 +42,12 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
 	var req CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.SendError(w, "Invalid request body", http.StatusBadRequest)
@@ -49,7 +55,7 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate input
-	if err := utils.ValidateCreateUserRequest(req.Username, req.Password, req.GroupId, req.AreaName, req.Role); err != nil {
+	if err := utils.ValidateCreateUserRequest(req.Username, req.Password, req.GroupId, req.Role); err != nil {
 		utils.SendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -59,6 +65,24 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Area Admins may only add users below their own role in their own area
+	if err := policy.CanAssignRole(actor, req.Role, req.GroupId); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	area, err := utils.ResolveArea(req.GroupId)
+	if err != nil {
+		utils.SendError(w, "Unknown area", http.StatusBadRequest)
+		return
+	}
+
+	// Enforce password policy
+	if err := utils.ValidatePasswordPolicy(req.Username, req.Password); err != nil {
+		utils.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Check for duplicate username
 	var existingUser models.User
 	result := db.DB.Unscoped().Where("username = ?", req.Username).First(&existingUser)
@@ -73,19 +97,23 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
 		utils.SendError(w, "Failed to process password", http.StatusInternalServerError)
 		return
 	}
 
 	// Create new user
+	now := time.Now()
 	user := models.User{
 		Username: req.Username,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		GroupId:  req.GroupId,
-		AreaName: req.AreaName,
+		AreaName: area.Name,
 		Role:     req.Role,
+		PasswordChangedAt:  &now,
+		PasswordExpiresAt:  utils.PasswordExpiry(now),
+		MustChangePassword: req.MustChangePassword,
 	}
 
 	// Insert into database
@@ -95,6 +123,9 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Seed password history so the initial password can't be reused later
+	utils.RecordPasswordHistory(user.ID, user.Password)
+
 	// Success response
 	utils.SendJSON(w, map[string]interface{}{
 		"message": "User created successfully!",
@@ -108,7 +139,8 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
-// GetUsersHandler retrieves all users
+// GetUsersHandler lists the users the caller may see: everyone for admins,
+// or the users they can manage in their areas for Area Admins
 func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 	utils.SetCORSHeaders(w)
 
@@ -121,9 +153,47 @@ func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var users []models.User
+	actor, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
 	// Don't return passwords in the response
-	db.DB.Select("id, username, group_id, area_name, role, created_at, updated_at").Find(&users)
+	query := db.DB.Select("id, username, group_id, area_name, role, created_at, updated_at")
+
+	areas := policy.Areas(actor, policy.UserView)
+	if areas.None() {
+		utils.SendError(w, "You are not allowed to list users", http.StatusForbidden)
+		return
+	}
+	if !areas.All {
+		// In each area, only users holding a role the caller can manage
+		var conditions []string
+		var args []interface{}
+		for _, groupId := range areas.GroupIDs {
+			roles, err := policy.ManageableRoles(actor, groupId)
+			if err != nil {
+				utils.SendError(w, "Failed to fetch users", http.StatusInternalServerError)
+				return
+			}
+			if roles == nil {
+				conditions = append(conditions, "group_id = ?")
+				args = append(args, groupId)
+			} else if len(roles) > 0 {
+				conditions = append(conditions, "(group_id = ? AND role IN ?)")
+				args = append(args, groupId, roles)
+			}
+		}
+		if len(conditions) == 0 {
+			utils.SendJSON(w, []models.User{}, http.StatusOK)
+			return
+		}
+		query = query.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	users := []models.User{}
+	query.Find(&users)
 
 	utils.SendJSON(w, users, http.StatusOK)
 }
@@ -141,6 +211,12 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
 	// Extract user ID from URL path (/users/5)
 	path := strings.TrimPrefix(r.URL.Path, "/users/")
 	userID, err := strconv.ParseUint(path, 10, 32)
@@ -170,57 +246,91 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The caller must manage the user now and, after a move or role change, still manage them
+	if err := policy.CanManageUser(actor, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if req.GroupId != 0 || req.Role != "" {
+		groupId, role := user.GroupId, user.Role
+		if req.GroupId != 0 {
+			groupId = req.GroupId
+		}
+		if req.Role != "" {
+			role = req.Role
+		}
+		if err := policy.CanAssignRole(actor, role, groupId); err != nil {
+			utils.SendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	// Enforce password policy and history
+	if req.Password != "" {
+		if err := utils.ValidatePasswordPolicy(user.Username, req.Password); err != nil {
+			utils.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.CheckPasswordHistory(&user, req.Password); err != nil {
+			utils.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Prepare update data
 	updateData := make(map[string]interface{})
 
 	// Only update fields that are provided
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if req.GroupId != 0 {
+		area, err := utils.ResolveArea(req.GroupId)
 		if err != nil {
-			utils.SendError(w, "Failed to process password", http.StatusInternalServerError)
+			utils.SendError(w, "Unknown area", http.StatusBadRequest)
 			return
 		}
-		updateData["password"] = string(hashedPassword)
-	}
-	if req.GroupId != 0 {
-		updateData["group_id"] = req.GroupId
-	}
-	if req.AreaName != "" {
-		updateData["area_name"] = req.AreaName
+		updateData["group_id"] = area.ID
+		updateData["area_name"] = area.Name
 	}
 	if req.Role != "" {
 		updateData["role"] = req.Role
 	}
+	if req.MustChangePassword != nil {
+		updateData["must_change_password"] = *req.MustChangePassword
+	}
 
 	// Check if there's anything to update
-	if len(updateData) == 0 {
+	if len(updateData) == 0 && req.Password == "" {
 		utils.SendError(w, "No fields provided for update", http.StatusBadRequest)
 		return
 	}
 
 	// Perform update
-	result := db.DB.Model(&user).Updates(updateData)
-	if result.Error != nil {
-		utils.SendError(w, "Failed to update user", http.StatusInternalServerError)
-		return
+	if len(updateData) > 0 {
+		result := db.DB.Model(&user).Updates(updateData)
+		if result.Error != nil {
+			utils.SendError(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	updatedFields := len(updateData)
+	if req.Password != "" {
+		mustChange := req.MustChangePassword != nil && *req.MustChangePassword
+		if err := utils.SetUserPassword(&user, req.Password, mustChange); err != nil {
+			utils.SendError(w, "Failed to process password", http.StatusInternalServerError)
+			return
+		}
+		updatedFields++
+
+		// A password change invalidates every existing session
+		utils.RevokeUserSessions(user.ID)
 	}
 
 	// Success response
 	utils.SendJSON(w, map[string]interface{}{
 		"message":        "User updated successfully!",
-		"updated_fields": len(updateData),
+		"updated_fields": updatedFields,
 	}, http.StatusOK)
 }
-// ValidateRole checks if the provided role is valid
-func ValidateRole(role string) error {
-	validRoles := []string{"admin", "user", "manager"} // Add your valid roles here
-	for _, r := range validRoles {
-		if role == r {
-			return nil
-		}
-	}
-	return fmt.Errorf("invalid role: %s", role)
-}
 
 // DeleteUserHandler deletes a user
 func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -235,6 +345,12 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor, err := utils.GetUserFromSession(r)
+	if err != nil {
+		utils.SendAuthError(w, err)
+		return
+	}
+
 	// Extract user ID from URL path (/users/5)
 	path := strings.TrimPrefix(r.URL.Path, "/users/")
 	userID, err := strconv.ParseUint(path, 10, 32)
@@ -250,12 +366,21 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := policy.CanManageUser(actor, &user); err != nil {
+		utils.SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Delete user
 	if err := db.DB.Exec("DELETE FROM users WHERE id = ?", user.ID).Error; err != nil {
 		utils.SendError(w, "Failed to delete user", http.StatusInternalServerError)
 		return
 	}
 
+	// Revoke any sessions the deleted user still holds
+	utils.RevokeUserSessions(user.ID)
+	utils.RemoveUserMemberships(user.ID)
+
 	// Success response
 	utils.SendJSON(w, map[string]interface{}{
 		"message":      "User deleted successfully!",
@@ -23,7 +23,7 @@ func CreateViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -40,6 +40,20 @@ func CreateViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The area must exist; its name is taken from the area record
+	area, err := utils.ResolveArea(req.GroupID)
+	if err != nil {
+		utils.SendError(w, "Unknown area", http.StatusBadRequest)
+		return
+	}
+	req.AreaName = area.Name
+
+	// Every camera must be registered and usable by the user
+	if err := utils.CheckNewCameras(user, utils.RequestCameraIDs(req.Cameras, req.CamerasMetadata), nil); err != nil {
+		sendCameraUseError(w, err)
+		return
+	}
+
 	// Check if view group already exists
 	if utils.CheckViewGroupExists(req.ID) {
 		utils.SendError(w, "View group with this ID already exists", http.StatusConflict)
@@ -82,7 +96,7 @@ func GetViewGroupsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -93,6 +107,11 @@ func GetViewGroupsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hide the cameras the user's camera rules do not allow
+	for i := range viewGroups {
+		utils.FilterViewGroupCameras(user, &viewGroups[i])
+	}
+
 	utils.SendJSON(w, viewGroups, http.StatusOK)
 }
 
@@ -112,7 +131,7 @@ func UpdateViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
@@ -143,6 +162,19 @@ func UpdateViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Cameras hidden from the user were not sent to them, so keep them
+	utils.KeepHiddenViewGroupCameras(user, viewGroup, req)
+
+	// Cameras added by this update must be registered and usable by the user
+	existing := append([]string{}, viewGroup.Cameras...)
+	for _, meta := range viewGroup.CamerasMetadata {
+		existing = append(existing, meta.ID)
+	}
+	if err := utils.CheckNewCameras(user, utils.RequestCameraIDs(req.Cameras, req.CamerasMetadata), existing); err != nil {
+		sendCameraUseError(w, err)
+		return
+	}
+
 	// Build update data
 	updateData, changes, err := utils.BuildUpdateData(req, viewGroup, user.Username)
 	if err != nil {
@@ -168,6 +200,9 @@ func UpdateViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch updated view group
 	updatedViewGroup, _ := utils.GetViewGroupByID(viewGroupID)
+	if updatedViewGroup != nil {
+		utils.FilterViewGroupCameras(user, updatedViewGroup)
+	}
 
 	// Success response
 	utils.SendJSON(w, map[string]interface{}{
@@ -193,7 +228,7 @@ func DeleteViewGroupHandler(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
 	user, err := utils.GetUserFromSession(r)
 	if err != nil {
-		utils.SendError(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendAuthError(w, err)
 		return
 	}
 
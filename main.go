@@ -4,14 +4,27 @@ import (
 	"go-auth/db"
 	"go-auth/handlers"
 	"go-auth/models"
+	"go-auth/policy"
+	"go-auth/utils"
 	"log"
 	"net/http"
+	"strings"
 )
 
 func main() {
 	db.Connect()
+
+	// Hash plaintext login tokens before the token column is narrowed
+	if err := utils.HashLegacyLoginTokens(); err != nil {
+		log.Fatal("Failed to hash legacy login tokens: ", err)
+	}
 	
-	
+	// Areas must exist before the tables referencing them get foreign keys
+	db.DB.AutoMigrate(&models.Area{})
+	if err := utils.BackfillAreas(); err != nil {
+		log.Fatal("Failed to create areas for existing group IDs: ", err)
+	}
+
 	db.DB.AutoMigrate(
 		&models.User{}, 
 		&models.Token{},
@@ -20,22 +33,114 @@ func main() {
 		&models.CustomMap{},
 		&models.CameraPosition{},
 		&models.UserPreference{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RecoveryCode{},
+		&models.UsedChallenge{},
+		&models.LoginThrottle{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.APIKey{},
+		&models.SigningKey{},
+		&models.Device{},
+		&models.ImpersonationAudit{},
+		&models.AuthEvent{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.AreaMembership{},
+		&models.ResourceGrant{},
+		&models.CameraRule{},
+		&models.Camera{},
+		&models.AccessSchedule{},
+		&models.AccessWindow{},
+		&models.AccessHoliday{},
+		&models.AccessScheduleAssignment{},
 	)
 
+	if err := policy.SeedRoles(); err != nil {
+		log.Fatal("Failed to seed roles: ", err)
+	}
+
+	// Legacy password hashes are upgraded as their users log in
+	if count, err := utils.CountLegacyPasswordHashes(); err == nil && count > 0 {
+		log.Printf("%d users still have legacy password hashes", count)
+	}
+
 	// Authentication routes
 	http.HandleFunc("/auth", handlers.AuthHandler)
 	http.HandleFunc("/login", handlers.LoginFormHandler)     // Browser login (form + redirect)
 	http.HandleFunc("/logout", handlers.LogoutHandler)       // Logout
 	http.HandleFunc("/session", handlers.SessionHandler)     // Get current session
+	http.HandleFunc("/refresh", handlers.RefreshHandler)     // Rotate refresh token, new access token
+	http.HandleFunc("/auth/mfa", handlers.MFAVerifyHandler)      // Complete JSON login with TOTP code
+	http.HandleFunc("/login/mfa", handlers.MFALoginFormHandler)  // Complete form login with TOTP code
+	http.HandleFunc("/auth/password/change", handlers.RequiredPasswordChangeHandler)       // Complete JSON login with a new password
+	http.HandleFunc("/login/password/change", handlers.RequiredPasswordChangeFormHandler)  // Complete form login with a new password
+
+	// Impersonation routes (start is /users/{id}/impersonate)
+	http.HandleFunc("/impersonation/stop", handlers.StopImpersonationHandler)
+	http.HandleFunc("/impersonation/audit", handlers.ImpersonationAuditHandler)
+	http.HandleFunc("/auth-events", handlers.AuthEventsHandler)
+
+	// Single sign-on routes
+	http.HandleFunc("/oidc/login", handlers.OIDCLoginHandler)
+	http.HandleFunc("/oidc/callback", handlers.OIDCCallbackHandler)
+
+	// Two-factor enrollment routes
+	http.HandleFunc("/mfa/totp/enroll", handlers.EnrollTOTPHandler)
+	http.HandleFunc("/mfa/totp/confirm", handlers.ConfirmTOTPHandler)
 	
 	// User management routes
 	http.HandleFunc("/users", handleUsers)
 	http.HandleFunc("/users/", handleSingleUser)
 
+	// Area routes
+	http.HandleFunc("/areas", handlers.AreasHandler)
+	http.HandleFunc("/areas/", handlers.AreaHandler)
+
+	// Role and permission routes
+	http.HandleFunc("/roles", handlers.RolesHandler)
+	http.HandleFunc("/roles/", handlers.RoleHandler)
+	http.HandleFunc("/permissions", handlers.PermissionsHandler)
+
+	// Camera routes
+	http.HandleFunc("/cameras", handlers.CamerasHandler)
+	http.HandleFunc("/cameras/", handlers.CameraHandler)
+	http.HandleFunc("/camera-rules", handlers.CameraRulesHandler)
+	http.HandleFunc("/camera-rules/", handlers.CameraRuleHandler)
+
+	// Access schedule routes
+	http.HandleFunc("/access-schedules", handlers.AccessSchedulesHandler)
+	http.HandleFunc("/access-schedules/", handlers.AccessScheduleHandler)
+
+	// Password routes
+	http.HandleFunc("/me/password", handlers.ChangeOwnPasswordHandler)
+	http.HandleFunc("/me/sessions", handlers.MySessionsHandler)
+	http.HandleFunc("/me/sessions/", handlers.MySessionHandler)
+	http.HandleFunc("/password-reset", handlers.RedeemPasswordResetHandler)
+	http.HandleFunc("/password-hashes/legacy", handlers.LegacyPasswordHashesHandler) // Admin: users not yet on argon2id
+
+	// API key routes
+	http.HandleFunc("/api-keys", handlers.APIKeysHandler)
+	http.HandleFunc("/api-keys/", handlers.RevokeAPIKeyHandler)
+
+	// Signing key routes
+	http.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler)
+	http.HandleFunc("/signing-keys", handlers.SigningKeysHandler)
+	http.HandleFunc("/signing-keys/", handleSingleSigningKey)
+
+	// Video-wall device routes
+	http.HandleFunc("/devices", handlers.DevicesHandler)                   // Admin: list / enroll
+	http.HandleFunc("/devices/", handlers.DeviceHandler)                   // Admin: reassign / revoke
+	http.HandleFunc("/device/view-group", handlers.DeviceViewGroupHandler) // Device: fetch assigned view group
+	http.HandleFunc("/device/heartbeat", handlers.DeviceHeartbeatHandler)  // Device: report last seen
+
 	// Token routes
 	http.HandleFunc("/tokens/generation", handlers.GenerateTokenHandler)
 	http.HandleFunc("/tokens/verify", handlers.VerifyTokenHandler)
 	http.HandleFunc("/verify", handlers.VerifyAndLoginHandler)
+	http.HandleFunc("/tokens", handlers.LoginTokensHandler)        // List or revoke all login links
+	http.HandleFunc("/tokens/", handlers.RevokeLoginTokenHandler)  // Revoke one login link
 
 	//view group routes
 	http.HandleFunc("/view-groups", handleViewGroups)  
@@ -80,6 +185,31 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSingleUser(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "/sessions") {
+		handlers.UserSessionsHandler(w, r)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/areas") {
+		handlers.UserAreasHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/mfa") {
+		handlers.ResetUserMFAHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/unlock") {
+		handlers.UnlockUserHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/impersonate") {
+		handlers.StartImpersonationHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/password-reset") {
+		handlers.IssuePasswordResetHandler(w, r)
+		return
+	}
+
 	switch r.Method {
 	case "PUT":
 		handlers.UpdateUserHandler(w, r)
@@ -92,6 +222,14 @@ func handleSingleUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handleSingleSigningKey(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/signing-keys/rotate" {
+		handlers.RotateSigningKeyHandler(w, r)
+		return
+	}
+	handlers.RetireSigningKeyHandler(w, r)
+}
+
 func handleViewGroups(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "POST":
@@ -106,6 +244,11 @@ func handleViewGroups(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSingleViewGroup(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "/grants") {
+		handlers.ViewGroupGrantsHandler(w, r)
+		return
+	}
+
 	switch r.Method {
 	case "PUT":
 		handlers.UpdateViewGroupHandler(w, r)
@@ -132,6 +275,11 @@ func handleCustomMaps(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSingleCustomMap(w http.ResponseWriter,r *http.Request) {
+	if strings.Contains(r.URL.Path, "/grants") {
+		handlers.CustomMapGrantsHandler(w, r)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		handlers.GetCustomMapHandler(w,r)
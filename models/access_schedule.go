@@ -0,0 +1,45 @@
+package models
+
+import "gorm.io/gorm"
+
+// AccessSchedule is a reusable weekly timetable of when its users and roles
+// may log in and use their sessions. Times are local to Timezone.
+type AccessSchedule struct {
+	gorm.Model
+	Name        string                     `gorm:"column:name;type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string                     `gorm:"column:description;type:varchar(255)" json:"description"`
+	Timezone    string                     `gorm:"column:timezone;type:varchar(64);not null;default:UTC" json:"timezone"` // IANA name, e.g. Europe/London
+	Windows     []AccessWindow             `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"windows"`
+	Holidays    []AccessHoliday            `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"holidays"`
+	Assignments []AccessScheduleAssignment `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"assignments"`
+}
+
+// AccessWindow allows access on one weekday between Start and End ("HH:MM").
+// An End at or before Start runs past midnight into the next day.
+type AccessWindow struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	ScheduleID uint   `gorm:"column:schedule_id;index;not null" json:"-"`
+	Weekday    int    `gorm:"column:weekday;not null" json:"weekday"` // 0 = Sunday
+	Start      string `gorm:"column:start_time;type:varchar(5);not null" json:"start"`
+	End        string `gorm:"column:end_time;type:varchar(5);not null" json:"end"`
+}
+
+// AccessHoliday replaces the weekly windows on one date: no access at all,
+// or only between Start and End when they are set
+type AccessHoliday struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	ScheduleID uint   `gorm:"column:schedule_id;index;not null" json:"-"`
+	Date       string `gorm:"column:date;type:varchar(10);not null" json:"date"` // YYYY-MM-DD
+	Name       string `gorm:"column:name;type:varchar(100)" json:"name,omitempty"`
+	Start      string `gorm:"column:start_time;type:varchar(5)" json:"start,omitempty"`
+	End        string `gorm:"column:end_time;type:varchar(5)" json:"end,omitempty"`
+}
+
+// AccessScheduleAssignment attaches a schedule to a single user or to
+// everyone holding a role
+type AccessScheduleAssignment struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	ScheduleID  uint   `gorm:"column:schedule_id;index;not null" json:"-"`
+	SubjectType string `gorm:"column:subject_type;type:varchar(10);index:idx_schedule_subject;not null" json:"subjectType"` // user, role
+	SubjectID   string `gorm:"column:subject_id;type:varchar(191);index:idx_schedule_subject;not null" json:"subjectId"`    // user ID or role name
+}
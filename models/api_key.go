@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey is a named, scoped credential for machine-to-machine clients.
+// Only the SHA-256 of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	gorm.Model
+	UserID     uint        `gorm:"column:user_id;index;not null" json:"userId"`
+	Name       string      `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	Prefix     string      `gorm:"column:prefix;type:varchar(16);index" json:"prefix"`
+	KeyHash    string      `gorm:"column:key_hash;type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     StringArray `gorm:"column:scopes;type:json" json:"scopes"`
+	LastUsedAt *time.Time  `gorm:"column:last_used_at" json:"lastUsedAt,omitempty"`
+	ExpiresAt  *time.Time  `gorm:"column:expires_at" json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time  `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+}
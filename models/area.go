@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Area is a site or region that users, view groups, maps and login links
+// belong to. Other tables reference it by group_id and keep a copy of its
+// name in area_name, which is updated when the area is renamed.
+type Area struct {
+	ID              int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name            string    `gorm:"column:name;type:varchar(191);uniqueIndex;not null" json:"name"`
+	ParentID        *int      `gorm:"column:parent_id;index" json:"parentId,omitempty"`
+	Parent          *Area     `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"-"`
+	IsHQ            bool      `gorm:"column:is_hq;default:false" json:"isHQ"`                       // HQ sees every area's view groups and maps
+	IncludeChildren bool      `gorm:"column:include_children;default:false" json:"includeChildren"` // sees view groups and maps of descendant areas
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// AreaMembership gives a user a role in an area besides their home GroupId,
+// e.g. a supervisor covering several areas with one account
+type AreaMembership struct {
+	gorm.Model
+	UserID   uint   `gorm:"column:user_id;uniqueIndex:idx_area_membership;not null" json:"userId"`
+	GroupID  int    `gorm:"column:group_id;uniqueIndex:idx_area_membership;not null" json:"groupId"`
+	AreaName string `gorm:"column:area_name;type:varchar(255)" json:"areaName"`
+	Area     *Area  `gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Role     string `gorm:"column:role;type:varchar(100);not null" json:"role"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// AuthEvent is one entry in the authentication log: a login attempt, a
+// login-link use or a logout
+type AuthEvent struct {
+	gorm.Model
+	UserID    *uint  `gorm:"column:user_id;index" json:"userId,omitempty"` // nil when the username is unknown
+	Username  string `gorm:"column:username;type:varchar(255);index" json:"username"`
+	Event     string `gorm:"column:event;type:varchar(30);index" json:"event"`     // login, logout, token_verify
+	Method    string `gorm:"column:method;type:varchar(20)" json:"method"`         // password, mfa, login_link, sso, impersonation
+	Outcome   string `gorm:"column:outcome;type:varchar(20);index" json:"outcome"` // success, failure, challenge
+	Reason    string `gorm:"column:reason;type:varchar(255)" json:"reason,omitempty"`
+	IPAddress string `gorm:"column:ip_address;type:varchar(64)" json:"ipAddress"`
+	UserAgent string `gorm:"column:user_agent;type:varchar(512)" json:"userAgent"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Camera is a registered camera. View groups and map positions refer to it by ID.
+type Camera struct {
+	ID           string    `gorm:"column:id;type:varchar(191);primaryKey" json:"id"`
+	Name         string    `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	GroupID      int       `gorm:"column:group_id;not null;index" json:"groupId"`
+	AreaName     string    `gorm:"column:area_name;type:varchar(255)" json:"areaName"`
+	Area         *Area     `gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	StreamURL    string    `gorm:"column:stream_url;type:text" json:"streamUrl,omitempty"`
+	SnapshotURL  string    `gorm:"column:snapshot_url;type:text" json:"snapshotUrl,omitempty"`
+	Manufacturer string    `gorm:"column:manufacturer;type:varchar(100)" json:"manufacturer,omitempty"`
+	ModelName    string    `gorm:"column:model;type:varchar(100)" json:"model,omitempty"`
+	Location     string    `gorm:"column:location;type:varchar(255)" json:"location,omitempty"`
+	Enabled      bool      `gorm:"column:enabled;default:true" json:"enabled"`
+	CreatedBy    string    `gorm:"column:created_by;type:varchar(255)" json:"createdBy,omitempty"`
+	UpdatedBy    string    `gorm:"column:updated_by;type:varchar(255)" json:"updatedBy,omitempty"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updatedAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// CameraRule allows or denies seeing one camera to an area, a single user or
+// everyone holding a role
+type CameraRule struct {
+	gorm.Model
+	CameraID    string `gorm:"column:camera_id;type:varchar(191);index;not null" json:"cameraId"`
+	SubjectType string `gorm:"column:subject_type;type:varchar(10);not null" json:"subjectType"` // area, user, role
+	SubjectID   string `gorm:"column:subject_id;type:varchar(191);not null" json:"subjectId"`    // area ID, user ID or role name
+	Effect      string `gorm:"column:effect;type:varchar(10);not null" json:"effect"`            // allow, deny
+	CreatedBy   string `gorm:"column:created_by;type:varchar(255)" json:"createdBy"`
+}
@@ -17,6 +17,7 @@ type CustomMap struct {
 	BoundsJSON  string    `gorm:"column:bounds;type:json" json:"bounds,omitempty"`
 	GroupID     int       `gorm:"column:group_id;not null;default:1;index" json:"groupId"`
 	AreaName    string    `gorm:"column:area_name;type:varchar(255)" json:"areaName"`
+	Area        *Area     `gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 	CreatedBy   string    `gorm:"column:created_by;type:varchar(255)" json:"createdBy,omitempty"`
 	UpdatedBy   string    `gorm:"column:updated_by;type:varchar(255)" json:"updatedBy,omitempty"`
 	CreatedAt   time.Time `gorm:"column:created_at" json:"createdAt"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device is an enrolled video-wall or kiosk display. Its credential can only
+// fetch the one view group it is assigned to; only the credential's SHA-256
+// is stored.
+type Device struct {
+	gorm.Model
+	Name           string     `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	ViewGroupID    string     `gorm:"column:view_group_id;type:varchar(191);index;not null" json:"viewGroupId"`
+	GroupId        int        `gorm:"column:group_id;index" json:"groupId"` // area of the assigned view group
+	Prefix         string     `gorm:"column:prefix;type:varchar(16);index" json:"prefix"`
+	CredentialHash string     `gorm:"column:credential_hash;type:varchar(64);uniqueIndex;not null" json:"-"`
+	EnrolledBy     string     `gorm:"column:enrolled_by;type:varchar(255)" json:"enrolledBy"`
+	LastSeenAt     *time.Time `gorm:"column:last_seen_at" json:"lastSeenAt,omitempty"`
+	LastSeenIP     string     `gorm:"column:last_seen_ip;type:varchar(64)" json:"lastSeenIp,omitempty"`
+	RevokedAt      *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+	Online         bool       `gorm:"-" json:"online"` // seen within DEVICE_OFFLINE_AFTER_SECONDS
+}
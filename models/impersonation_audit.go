@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// ImpersonationAudit records the start and end of an impersonation session
+// and every request made while it was active
+type ImpersonationAudit struct {
+	gorm.Model
+	SessionID        uint   `gorm:"column:session_id;index" json:"sessionId"`
+	ImpersonatorID   uint   `gorm:"column:impersonator_id;index" json:"impersonatorId"`
+	ImpersonatorName string `gorm:"column:impersonator_name;type:varchar(255)" json:"impersonatorName"`
+	TargetUserID     uint   `gorm:"column:target_user_id;index" json:"targetUserId"`
+	TargetUsername   string `gorm:"column:target_username;type:varchar(255)" json:"targetUsername"`
+	Action           string `gorm:"column:action;type:varchar(20)" json:"action"` // start, stop, request, blocked
+	Method           string `gorm:"column:method;type:varchar(10)" json:"method,omitempty"`
+	Path             string `gorm:"column:path;type:varchar(512)" json:"path,omitempty"`
+	IPAddress        string `gorm:"column:ip_address;type:varchar(64)" json:"ipAddress"`
+	Reason           string `gorm:"column:reason;type:varchar(512)" json:"reason,omitempty"`
+}
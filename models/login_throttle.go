@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginThrottle tracks consecutive failed logins for one username or client IP
+type LoginThrottle struct {
+	gorm.Model
+	ThrottleKey   string     `gorm:"column:throttle_key;type:varchar(255);uniqueIndex;not null" json:"key"` // "user:<name>" or "ip:<addr>"
+	Failures      int        `gorm:"column:failures;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at" json:"lastFailureAt"`
+	BlockedUntil  *time.Time `gorm:"column:blocked_until" json:"blockedUntil,omitempty"`
+}
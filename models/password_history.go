@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// PasswordHistory keeps recent password hashes so they can't be reused
+type PasswordHistory struct {
+	gorm.Model
+	UserID       uint   `gorm:"column:user_id;index;not null" json:"userId"`
+	PasswordHash string `gorm:"column:password_hash;not null" json:"-"`
+}
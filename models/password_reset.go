@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a hashed, single-use, admin-issued password reset token
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"column:user_id;index;not null" json:"userId"`
+	TokenHash string     `gorm:"column:token_hash;type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"usedAt,omitempty"`
+	CreatedBy string     `gorm:"column:created_by;type:varchar(255)" json:"createdBy"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a hashed single-use backup code for TOTP two-factor login
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"column:user_id;index;not null" json:"userId"`
+	CodeHash string     `gorm:"column:code_hash;type:varchar(64);not null" json:"-"`
+	UsedAt   *time.Time `gorm:"column:used_at" json:"usedAt,omitempty"`
+}
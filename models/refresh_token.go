@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque, single-use token that mints a new access JWT.
+// Every refresh token descending from one login shares the SessionID, which
+// identifies the token family.
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint       `gorm:"column:session_id;index;not null" json:"sessionId"`
+	UserID    uint       `gorm:"column:user_id;index" json:"userId"`
+	TokenHash string     `gorm:"column:token_hash;type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"usedAt,omitempty"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResourceGrant shares one view group or custom map outside its area, with
+// another area, a single user or everyone holding a role
+type ResourceGrant struct {
+	gorm.Model
+	ResourceType string     `gorm:"column:resource_type;type:varchar(20);index:idx_resource_grant_resource;not null" json:"resourceType"` // view_group, custom_map
+	ResourceID   string     `gorm:"column:resource_id;type:varchar(191);index:idx_resource_grant_resource;not null" json:"resourceId"`
+	GranteeType  string     `gorm:"column:grantee_type;type:varchar(10);index:idx_resource_grant_grantee;not null" json:"granteeType"` // area, user, role
+	GranteeID    string     `gorm:"column:grantee_id;type:varchar(191);index:idx_resource_grant_grantee;not null" json:"granteeId"`    // area ID, user ID or role name
+	Access       string     `gorm:"column:access;type:varchar(10);not null" json:"access"`                                             // read, edit
+	ExpiresAt    *time.Time `gorm:"column:expires_at" json:"expiresAt,omitempty"`
+	GrantedBy    string     `gorm:"column:granted_by;type:varchar(255)" json:"grantedBy"`
+}
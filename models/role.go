@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// Role is a named set of permissions. Users reference roles by Name.
+type Role struct {
+	gorm.Model
+	Name        string           `gorm:"column:name;type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string           `gorm:"column:description;type:varchar(255)" json:"description"`
+	BuiltIn     bool             `gorm:"column:built_in;default:false" json:"builtIn"` // seeded roles cannot be deleted
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE" json:"permissions"`
+}
+
+// RolePermission grants one permission to a role, either everywhere ("all")
+// or only within the user's own area ("area")
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	RoleID     uint   `gorm:"column:role_id;index;not null" json:"-"`
+	Permission string `gorm:"column:permission;type:varchar(64);not null" json:"permission"`
+	Scope      string `gorm:"column:scope;type:varchar(10);not null;default:all" json:"scope"`
+}
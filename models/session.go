@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is the server-side record of an issued session JWT, keyed by its jti claim
+type Session struct {
+	gorm.Model
+	JTI       string     `gorm:"column:jti;type:varchar(64);uniqueIndex;not null" json:"-"`
+	UserID    uint       `gorm:"column:user_id;index" json:"userId"`
+	Username  string     `gorm:"column:username;type:varchar(255)" json:"username"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+	CSRFToken string     `gorm:"column:csrf_token;type:varchar(64)" json:"-"` // synchronizer token for mutating requests
+
+	// Where and how the session was started, for the active sessions view
+	LoginMethod string     `gorm:"column:login_method;type:varchar(20)" json:"loginMethod"` // password, login_link, sso, impersonation
+	IPAddress   string     `gorm:"column:ip_address;type:varchar(64)" json:"ipAddress"`
+	UserAgent   string     `gorm:"column:user_agent;type:varchar(512)" json:"userAgent"`
+	LastSeenAt  *time.Time `gorm:"column:last_seen_at" json:"lastSeenAt,omitempty"`
+	Current     bool       `gorm:"-" json:"current"` // the session making the request
+
+	// Restrictions inherited from the login link that started the session
+	LoginTokenID *uint       `gorm:"column:login_token_id;index" json:"loginTokenId,omitempty"`
+	ReadOnly     bool        `gorm:"column:read_only;default:false" json:"readOnly"`
+	ViewGroupIDs StringArray `gorm:"column:view_group_ids;type:json" json:"viewGroupIds"`
+
+	// Set when an admin is viewing the app as this session's user
+	ImpersonatorID   *uint  `gorm:"column:impersonator_id;index" json:"impersonatorId,omitempty"`
+	ImpersonatorName string `gorm:"column:impersonator_name;type:varchar(255)" json:"impersonatorName,omitempty"`
+	ParentSessionID  *uint  `gorm:"column:parent_session_id" json:"-"` // the admin's own session, resumed on stop
+	AllowDestructive bool   `gorm:"column:allow_destructive;default:false" json:"allowDestructive"`
+}
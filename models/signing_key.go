@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SigningKey is one key in the JWT key ring. Exactly one key is Active and
+// signs new tokens; rotated-out keys keep verifying until they are retired
+// or the retention period has passed.
+type SigningKey struct {
+	gorm.Model
+	Kid       string     `gorm:"column:kid;type:varchar(64);uniqueIndex;not null" json:"kid"`
+	Alg       string     `gorm:"column:alg;type:varchar(10);not null" json:"alg"` // HS256, RS256 or EdDSA
+	Material  string     `gorm:"column:material;type:text;not null" json:"-"`     // HMAC secret or PKCS#8 private key, base64
+	Active    bool       `gorm:"column:active;default:false;index" json:"active"`
+	RotatedAt *time.Time `gorm:"column:rotated_at" json:"rotatedAt,omitempty"` // when it stopped signing
+	RetiredAt *time.Time `gorm:"column:retired_at" json:"retiredAt,omitempty"` // when it stopped verifying
+}
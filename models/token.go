@@ -5,15 +5,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// Token is a login link. Only the SHA-256 of the link token is stored in the
+// token column. Sessions started from a link inherit its restrictions.
 type Token struct {
 	gorm.Model
-	UserID    uint       `gorm:"column:user_id" json:"userId"`
-	Username  string     `gorm:"column:username;type:varchar(255)" json:"username"`
-	GroupId   int        `gorm:"column:group_id" json:"groupId"`
-	AreaName  string     `gorm:"column:area_name;type:varchar(255)" json:"areaName"`
-	Role      string     `gorm:"column:role;type:varchar(100)" json:"role"`
-	Token     string     `gorm:"column:token;type:text;not null" json:"token"`
-	IsUsed    bool       `gorm:"column:is_used;default:false" json:"isUsed"`
-	UsedAt    *time.Time `gorm:"column:used_at" json:"usedAt,omitempty"`
-	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
-}
\ No newline at end of file
+	UserID       uint        `gorm:"column:user_id;index" json:"userId"`
+	Username     string      `gorm:"column:username;type:varchar(255)" json:"username"`
+	GroupId      int         `gorm:"column:group_id" json:"groupId"`
+	AreaName     string      `gorm:"column:area_name;type:varchar(255)" json:"areaName"`
+	Area         *Area       `gorm:"foreignKey:GroupId;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Role         string      `gorm:"column:role;type:varchar(100)" json:"role"`
+	Token        string      `gorm:"column:token;type:varchar(64);uniqueIndex;not null" json:"-"`
+	Label        string      `gorm:"column:label;type:varchar(255)" json:"label"`
+	IsUsed       bool        `gorm:"column:is_used;default:false" json:"isUsed"`
+	UsedAt       *time.Time  `gorm:"column:used_at" json:"usedAt,omitempty"`
+	LastUsedAt   *time.Time  `gorm:"column:last_used_at" json:"lastUsedAt,omitempty"`
+	UseCount     int         `gorm:"column:use_count;default:0" json:"useCount"`
+	MaxUses      int         `gorm:"column:max_uses;default:0" json:"maxUses"` // 0 = unlimited
+	ReadOnly     bool        `gorm:"column:read_only;default:false" json:"readOnly"`
+	ViewGroupIDs StringArray `gorm:"column:view_group_ids;type:json" json:"viewGroupIds"` // empty = no restriction
+	ExpiresAt    time.Time   `gorm:"column:expires_at" json:"expiresAt"`
+	RevokedAt    *time.Time  `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+}
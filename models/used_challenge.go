@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// UsedChallenge records a consumed login challenge token (password change,
+// MFA) by its jti so it cannot be replayed before it expires
+type UsedChallenge struct {
+	JTI       string    `gorm:"column:jti;type:varchar(64);primaryKey" json:"-"`
+	Purpose   string    `gorm:"column:purpose;type:varchar(32);not null" json:"-"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index;not null" json:"-"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
+}
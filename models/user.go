@@ -1,12 +1,39 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct{
 	gorm.Model
 	Username string `gorm:"unique;not null" json:"username"`
 	Password string `gorm:"not null" json:"password,omitempty"`
 	GroupId int `json:"groupId"` 
+	Area *Area `gorm:"foreignKey:GroupId;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 	AreaName string `json:"areaName"`
 	Role string `json:"role"`
+	TOTPSecret string `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
+	TOTPEnabled bool `gorm:"column:totp_enabled;default:false" json:"totpEnabled"`
+	TOTPLastStep int64 `gorm:"column:totp_last_step;default:0" json:"-"`
+	PasswordChangedAt *time.Time `gorm:"column:password_changed_at" json:"passwordChangedAt,omitempty"`
+	PasswordExpiresAt *time.Time `gorm:"column:password_expires_at" json:"passwordExpiresAt,omitempty"`
+	MustChangePassword bool `gorm:"column:must_change_password;default:false" json:"mustChangePassword"`
+	AuthProvider string `gorm:"column:auth_provider;type:varchar(50);default:local" json:"authProvider"` // local, oidc
+	ExternalID string `gorm:"column:external_id;type:varchar(255);index" json:"-"` // subject at the external provider
+	Auth *AuthContext `gorm:"-" json:"-"` // how the current request authenticated; set by GetUserFromSession
+	Memberships []AreaMembership `gorm:"-" json:"-"` // extra areas; loaded on demand by the policy package
+}
+
+// AuthContext describes the credential behind a request and any restrictions it carries
+type AuthContext struct {
+	Method       string   // "session" or "api_key"
+	SessionID    uint     // set for browser sessions
+	ReadOnly     bool     // only GET requests are allowed
+	ViewGroupIDs []string // when set, only these view groups are visible
+	LoginTokenID *uint    // set when the session was opened from a login link
+
+	ImpersonatorID   *uint  // set while an admin is acting as this user
+	ImpersonatorName string
 }
\ No newline at end of file
@@ -62,6 +62,7 @@ type ViewGroup struct {
 	Name                 string      `gorm:"not null" json:"name"`
 	GroupID              int         `gorm:"not null;index" json:"groupId"`
 	AreaName             string      `json:"areaName"`
+	Area                 *Area       `gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
     IsHQ                 bool        `gorm:"default:false" json:"isHQ"`
 	Cameras              StringArray `gorm:"type:json" json:"cameras"`
 	CamerasMetadata      CameraMetadataArray `gorm:"type:json" json:"camerasMetadata"` 
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// inheritedActions are the permissions a parent area extends to its
+// descendants when it includes children, and an HQ area to every area
+var inheritedActions = map[string]bool{
+	ViewGroupView: true,
+	MapView:       true,
+}
+
+// areaCache holds the area tree so visibility checks do not hit the database
+var areaCache struct {
+	sync.Mutex
+	areas    map[int]models.Area
+	children map[int][]int
+	loadedAt time.Time
+}
+
+// areaTree returns the cached areas and each area's direct children,
+// reloading them when stale. If the reload fails the previous tree is kept.
+func areaTree() (map[int]models.Area, map[int][]int) {
+	areaCache.Lock()
+	defer areaCache.Unlock()
+
+	if areaCache.areas == nil || time.Since(areaCache.loadedAt) >= time.Duration(config.ROLE_CACHE_SECONDS)*time.Second {
+		var records []models.Area
+		if err := db.DB.Find(&records).Error; err == nil {
+			areas := make(map[int]models.Area, len(records))
+			children := make(map[int][]int)
+			for _, area := range records {
+				areas[area.ID] = area
+				if area.ParentID != nil {
+					children[*area.ParentID] = append(children[*area.ParentID], area.ID)
+				}
+			}
+			areaCache.areas = areas
+			areaCache.children = children
+			areaCache.loadedAt = time.Now()
+		}
+	}
+
+	return areaCache.areas, areaCache.children
+}
+
+// InvalidateAreas makes the next check reload the area tree from the database
+func InvalidateAreas() {
+	areaCache.Lock()
+	areaCache.loadedAt = time.Time{}
+	areaCache.Unlock()
+}
+
+// withDescendants widens scope by the areas its HQ and include-children areas can see
+func withDescendants(scope AreaScope) AreaScope {
+	if scope.All {
+		return scope
+	}
+
+	areas, children := areaTree()
+	expanded := AreaScope{}
+	var visit func(groupID int)
+	visit = func(groupID int) {
+		if expanded.Allows(groupID) {
+			return
+		}
+		expanded.GroupIDs = append(expanded.GroupIDs, groupID)
+		if areas[groupID].IncludeChildren {
+			for _, child := range children[groupID] {
+				visit(child)
+			}
+		}
+	}
+
+	for _, groupID := range scope.GroupIDs {
+		if areas[groupID].IsHQ {
+			return AreaScope{All: true}
+		}
+		visit(groupID)
+	}
+	return expanded
+}
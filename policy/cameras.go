@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// Camera rule effects
+const (
+	CameraAllow = "allow"
+	CameraDeny  = "deny"
+)
+
+// cameraCache holds the camera rules by camera ID so filtering responses does
+// not hit the database
+var cameraCache struct {
+	sync.Mutex
+	rules    map[string][]models.CameraRule
+	loadedAt time.Time
+}
+
+// cameraRules returns the rules on a camera, reloading the cache when stale.
+// If the reload fails the previous rules are kept.
+func cameraRules(cameraID string) []models.CameraRule {
+	cameraCache.Lock()
+	defer cameraCache.Unlock()
+
+	if cameraCache.rules == nil || time.Since(cameraCache.loadedAt) >= time.Duration(config.ROLE_CACHE_SECONDS)*time.Second {
+		var records []models.CameraRule
+		if err := db.DB.Find(&records).Error; err == nil {
+			rules := make(map[string][]models.CameraRule)
+			for _, rule := range records {
+				rules[rule.CameraID] = append(rules[rule.CameraID], rule)
+			}
+			cameraCache.rules = rules
+			cameraCache.loadedAt = time.Now()
+		}
+	}
+
+	return cameraCache.rules[cameraID]
+}
+
+// InvalidateCameraRules makes the next check reload camera rules from the database
+func InvalidateCameraRules() {
+	cameraCache.Lock()
+	cameraCache.loadedAt = time.Time{}
+	cameraCache.Unlock()
+}
+
+// ruleMatches reports whether a camera rule names user, one of their roles or
+// one of their areas
+func ruleMatches(user *models.User, rule models.CameraRule) bool {
+	switch rule.SubjectType {
+	case GranteeUser:
+		return rule.SubjectID == strconv.FormatUint(uint64(user.ID), 10)
+	case GranteeRole:
+		if rule.SubjectID == user.Role {
+			return true
+		}
+		for _, membership := range Memberships(user) {
+			if rule.SubjectID == membership.Role {
+				return true
+			}
+		}
+	case GranteeArea:
+		if rule.SubjectID == strconv.Itoa(user.GroupId) {
+			return true
+		}
+		for _, membership := range Memberships(user) {
+			if rule.SubjectID == strconv.Itoa(membership.GroupID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanViewCamera reports whether user may see a camera. Cameras without rules
+// are visible to everyone who can see the view group or map holding them. A
+// matching deny rule always hides the camera; once a camera has any allow
+// rule, only users matching one of them see it. Roles holding every
+// permission see every camera.
+func CanViewCamera(user *models.User, cameraID string) bool {
+	rules := cameraRules(cameraID)
+	if len(rules) == 0 || hasEverything(roleGrants(user.Role)) {
+		return true
+	}
+
+	allowListed, allowed := false, false
+	for _, rule := range rules {
+		switch rule.Effect {
+		case CameraDeny:
+			if ruleMatches(user, rule) {
+				return false
+			}
+		case CameraAllow:
+			allowListed = true
+			if ruleMatches(user, rule) {
+				allowed = true
+			}
+		}
+	}
+	return allowed || !allowListed
+}
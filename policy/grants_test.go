@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-auth/models"
+)
+
+// useTestRoles replaces the role cache so checks do not need the roles table
+func useTestRoles(t *testing.T, grants map[string]map[string]string) {
+	roleCache.Lock()
+	previous, previousLoadedAt := roleCache.grants, roleCache.loadedAt
+	roleCache.grants, roleCache.loadedAt = grants, time.Now().Add(time.Hour)
+	roleCache.Unlock()
+
+	t.Cleanup(func() {
+		roleCache.Lock()
+		roleCache.grants, roleCache.loadedAt = previous, previousLoadedAt
+		roleCache.Unlock()
+	})
+}
+
+func TestGrantAccessFor(t *testing.T) {
+	tests := []struct {
+		action string
+		want   []string
+	}{
+		{ViewGroupView, []string{GrantRead, GrantEdit}},
+		{MapView, []string{GrantRead, GrantEdit}},
+		{ViewGroupUpdate, []string{GrantEdit}},
+		{MapUpdate, []string{GrantEdit}},
+		{ViewGroupDelete, nil},
+		{MapDelete, nil},
+	}
+	for _, tt := range tests {
+		got := grantAccessFor(tt.action)
+		if len(got) != len(tt.want) {
+			t.Errorf("grantAccessFor(%s) = %v, want %v", tt.action, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("grantAccessFor(%s) = %v, want %v", tt.action, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDeleteSharedResource(t *testing.T) {
+	useTestRoles(t, map[string]map[string]string{
+		AdminRole:    {AllPermissions: ScopeAll},
+		"Area Admin": {ViewGroupDelete: ScopeArea, MapDelete: ScopeArea},
+		"Basic User": {ViewGroupView: ScopeArea, MapView: ScopeArea},
+	})
+
+	user := func(role string) *models.User {
+		return &models.User{GroupId: 1, Role: role, Memberships: []models.AreaMembership{}}
+	}
+	own := Resource{GroupID: 1, Type: ResourceViewGroup, ID: "vg-own"}
+	shared := Resource{GroupID: 2, Type: ResourceViewGroup, ID: "vg-shared"}
+	sharedMap := Resource{GroupID: 2, Type: ResourceCustomMap, ID: "7"}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		action   string
+		resource Resource
+		allowed  bool
+	}{
+		{"owning area", user("Area Admin"), ViewGroupDelete, own, true},
+		{"admin", user(AdminRole), ViewGroupDelete, shared, true},
+		{"admin map", user(AdminRole), MapDelete, sharedMap, true},
+		// Grants are not consulted for deletes, so an edit grant on the
+		// shared resource cannot let another area delete it
+		{"grantee area", user("Area Admin"), ViewGroupDelete, shared, false},
+		{"grantee area map", user("Area Admin"), MapDelete, sharedMap, false},
+		{"no delete permission", user("Basic User"), ViewGroupDelete, own, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(tt.user, tt.action, tt.resource)
+			if tt.allowed && err != nil {
+				t.Fatalf("Authorize = %v, want allowed", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("Authorize = %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
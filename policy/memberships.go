@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// Memberships returns user's extra area memberships, loading them on first use
+func Memberships(user *models.User) []models.AreaMembership {
+	if user.Memberships == nil {
+		memberships := []models.AreaMembership{}
+		if user.ID != 0 {
+			db.DB.Where("user_id = ?", user.ID).Order("group_id").Find(&memberships)
+		}
+		user.Memberships = memberships
+	}
+	return user.Memberships
+}
+
+// rolesIn returns the roles user holds in an area for action: the home role
+// when it covers the area, plus the role of a membership in that area
+func rolesIn(user *models.User, groupID int, action string) []string {
+	var roles []string
+	if groupID == user.GroupId || grantScope(roleGrants(user.Role), action) == ScopeAll {
+		roles = append(roles, user.Role)
+	}
+	for _, membership := range Memberships(user) {
+		if membership.GroupID == groupID {
+			roles = append(roles, membership.Role)
+		}
+	}
+	return roles
+}
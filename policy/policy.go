@@ -0,0 +1,208 @@
+// Package policy decides what a user may do. Roles and their permissions are
+// stored in the database; handlers ask Authorize instead of comparing role names.
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"go-auth/models"
+)
+
+// Permissions understood by Authorize
+const (
+	ViewGroupView   = "viewgroup:view"
+	ViewGroupCreate = "viewgroup:create"
+	ViewGroupUpdate = "viewgroup:update"
+	ViewGroupDelete = "viewgroup:delete"
+
+	MapView   = "map:view"
+	MapCreate = "map:create"
+	MapUpdate = "map:update"
+	MapDelete = "map:delete"
+
+	UserView        = "user:view"
+	UserManage      = "user:manage" // sessions, MFA, lockouts and password resets of other users
+	UserImpersonate = "user:impersonate"
+
+	TokenManage    = "token:manage"  // other users' login links
+	APIKeyManage   = "apikey:manage" // other users' API keys
+	DeviceManage   = "device:manage"
+	KeyManage      = "key:manage" // JWT signing keys
+	AreaManage     = "area:manage"
+	CameraManage   = "camera:manage"   // camera visibility rules
+	ScheduleManage = "schedule:manage" // access schedules
+	AuditView      = "audit:view"      // auth events and impersonation audit
+	RoleManage     = "role:manage"
+
+	AllPermissions = "*"
+)
+
+// Permissions lists every permission a role can be granted
+var Permissions = []string{
+	ViewGroupView, ViewGroupCreate, ViewGroupUpdate, ViewGroupDelete,
+	MapView, MapCreate, MapUpdate, MapDelete,
+	UserView, UserManage, UserImpersonate,
+	TokenManage, APIKeyManage, DeviceManage, KeyManage, AreaManage, CameraManage, ScheduleManage, AuditView, RoleManage,
+}
+
+// Permission scopes
+const (
+	ScopeNone = ""
+	ScopeArea = "area" // only resources in the user's own area
+	ScopeAll  = "all"
+)
+
+// ErrForbidden is wrapped by every error Authorize returns
+var ErrForbidden = errors.New("forbidden")
+
+// Resource describes what an action is performed on. The zero Resource means
+// the action in general, e.g. listing, where results are filtered by Areas.
+type Resource struct {
+	GroupID int    // owning area; 0 when the resource is not tied to one
+	OwnerID uint   // owning user for self-service resources such as tokens and API keys
+	Type    string // shareable resource type and ID, set when grants on it apply
+	ID      string
+}
+
+// InArea returns a resource belonging to the given area
+func InArea(groupID int) Resource {
+	return Resource{GroupID: groupID}
+}
+
+// OwnedBy returns a resource belonging to owner. It is in the owner's area, so
+// a role granting the action in one area only reaches that area's users.
+func OwnedBy(owner *models.User) Resource {
+	return Resource{OwnerID: owner.ID, GroupID: owner.GroupId}
+}
+
+// loginLinkActions are the only permissions a session opened from a login link
+// keeps. Such sessions may not manage users, mint credentials or administer anything.
+var loginLinkActions = map[string]bool{
+	ViewGroupView: true, ViewGroupCreate: true, ViewGroupUpdate: true, ViewGroupDelete: true,
+	MapView: true, MapCreate: true, MapUpdate: true, MapDelete: true,
+}
+
+// FromLoginLink reports whether user's request comes from a session opened with a login link
+func FromLoginLink(user *models.User) bool {
+	return user.Auth != nil && user.Auth.LoginTokenID != nil
+}
+
+// linkRestricted reports whether action is withheld from user's login-link session
+func linkRestricted(user *models.User, action string) bool {
+	return FromLoginLink(user) && !loginLinkActions[action]
+}
+
+// Authorize returns nil if user may perform action on resource. Users may
+// always act on resources they own, except from a login-link session. Otherwise a role must grant the
+// permission for the resource's area: the user's home role covers their own
+// area (or every area with an "all" grant) and each area membership's role
+// covers that membership's area. Failing that, a shared resource is allowed
+// when one of its grants reaches the user.
+func Authorize(user *models.User, action string, resource Resource) error {
+	if linkRestricted(user, action) {
+		return fmt.Errorf("%w: %s is not allowed from a login-link session", ErrForbidden, action)
+	}
+	if resource.OwnerID != 0 && resource.OwnerID == user.ID {
+		return nil
+	}
+
+	var err error
+	areas := Areas(user, action)
+	if areas.None() {
+		err = fmt.Errorf("%w: your role does not allow %s", ErrForbidden, action)
+	} else if resource.GroupID != 0 && !areas.Allows(resource.GroupID) {
+		err = fmt.Errorf("%w: %s is not allowed in this area", ErrForbidden, action)
+	}
+
+	if err != nil && resource.Type != "" && grantAllows(user, action, resource) {
+		return nil
+	}
+	return err
+}
+
+// AreaScope is the set of areas in which a user may perform an action
+type AreaScope struct {
+	All      bool
+	GroupIDs []int
+}
+
+// None reports whether the action is allowed nowhere
+func (s AreaScope) None() bool {
+	return !s.All && len(s.GroupIDs) == 0
+}
+
+// Allows reports whether the action is allowed in the given area
+func (s AreaScope) Allows(groupID int) bool {
+	if s.All {
+		return true
+	}
+	for _, id := range s.GroupIDs {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// Areas returns where user may perform action, combining the home role with
+// every area membership. View permissions also reach the descendants of areas
+// that include their children, and every area from an HQ area.
+func Areas(user *models.User, action string) AreaScope {
+	var areas AreaScope
+	if linkRestricted(user, action) {
+		return areas
+	}
+	switch Scope(user, action) {
+	case ScopeAll:
+		return AreaScope{All: true}
+	case ScopeArea:
+		areas.GroupIDs = append(areas.GroupIDs, user.GroupId)
+	}
+
+	for _, membership := range Memberships(user) {
+		if grantScope(roleGrants(membership.Role), action) != ScopeNone && !areas.Allows(membership.GroupID) {
+			areas.GroupIDs = append(areas.GroupIDs, membership.GroupID)
+		}
+	}
+
+	if inheritedActions[action] {
+		return withDescendants(areas)
+	}
+	return areas
+}
+
+// Scope returns how widely user's home role grants action: ScopeAll, ScopeArea
+// or ScopeNone. Area memberships are not included; see Areas.
+func Scope(user *models.User, action string) string {
+	if linkRestricted(user, action) {
+		return ScopeNone
+	}
+	return grantScope(roleGrants(user.Role), action)
+}
+
+// grantScope returns the scope at which grants include action
+func grantScope(grants map[string]string, action string) string {
+	if scope := grants[AllPermissions]; scope == ScopeAll {
+		return ScopeAll
+	}
+
+	scope := grants[action]
+	if grants[AllPermissions] == ScopeArea && scope == ScopeNone {
+		scope = ScopeArea
+	}
+	return scope
+}
+
+// IsKnownPermission reports whether permission can be granted to a role
+func IsKnownPermission(permission string) bool {
+	if permission == AllPermissions {
+		return true
+	}
+	for _, known := range Permissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"gorm.io/gorm"
+)
+
+// AdminRole always holds every permission so the system cannot be locked out
+const AdminRole = "admin"
+
+// Errors returned by the role store
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleInUse    = errors.New("role is still assigned to users or named by area memberships, grants, camera rules or schedules")
+	ErrRoleBuiltIn  = errors.New("built-in roles cannot be deleted")
+	ErrRoleLocked   = errors.New("the admin role cannot be changed")
+)
+
+// builtInRoles are created on startup when missing; existing rows are left as edited
+var builtInRoles = []models.Role{
+	{
+		Name:        AdminRole,
+		Description: "Full access to every area and setting",
+		Permissions: []models.RolePermission{
+			{Permission: AllPermissions, Scope: ScopeAll},
+		},
+	},
+	{
+		Name:        "Area Admin",
+		Description: "Manages view groups, maps and Basic Users in their own area",
+		Permissions: []models.RolePermission{
+			{Permission: ViewGroupView, Scope: ScopeArea},
+			{Permission: ViewGroupCreate, Scope: ScopeArea},
+			{Permission: ViewGroupUpdate, Scope: ScopeArea},
+			{Permission: ViewGroupDelete, Scope: ScopeArea},
+			{Permission: MapView, Scope: ScopeArea},
+			{Permission: MapCreate, Scope: ScopeArea},
+			{Permission: MapUpdate, Scope: ScopeArea},
+			{Permission: MapDelete, Scope: ScopeArea},
+			{Permission: AuditView, Scope: ScopeArea},
+			{Permission: UserView, Scope: ScopeArea},
+			{Permission: UserManage, Scope: ScopeArea},
+		},
+	},
+	{
+		Name:        "Basic User",
+		Description: "Views their own area's view groups and maps",
+		Permissions: []models.RolePermission{
+			{Permission: ViewGroupView, Scope: ScopeArea},
+			{Permission: MapView, Scope: ScopeArea},
+		},
+	},
+}
+
+// roleCache holds each role's permission grants so checks do not hit the database
+var roleCache struct {
+	sync.Mutex
+	grants   map[string]map[string]string // role name -> permission -> scope
+	loadedAt time.Time
+}
+
+// SeedRoles creates any missing built-in role
+func SeedRoles() error {
+	for _, role := range builtInRoles {
+		var count int64
+		if err := db.DB.Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		role.BuiltIn = true
+		if err := db.DB.Create(&role).Error; err != nil {
+			return err
+		}
+	}
+	invalidateRoles()
+	return nil
+}
+
+// roleGrants returns the permission grants of a role, reloading the cache when stale.
+// If the reload fails the previous grants are kept.
+func roleGrants(name string) map[string]string {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+
+	if roleCache.grants == nil || time.Since(roleCache.loadedAt) >= time.Duration(config.ROLE_CACHE_SECONDS)*time.Second {
+		var roles []models.Role
+		if err := db.DB.Preload("Permissions").Find(&roles).Error; err == nil {
+			grants := make(map[string]map[string]string, len(roles))
+			for _, role := range roles {
+				grants[role.Name] = make(map[string]string, len(role.Permissions))
+				for _, permission := range role.Permissions {
+					if grants[role.Name][permission.Permission] != ScopeAll {
+						grants[role.Name][permission.Permission] = permission.Scope
+					}
+				}
+			}
+			roleCache.grants = grants
+			roleCache.loadedAt = time.Now()
+		}
+	}
+
+	return roleCache.grants[name]
+}
+
+// invalidateRoles makes the next check reload roles from the database
+func invalidateRoles() {
+	roleCache.Lock()
+	roleCache.loadedAt = time.Time{}
+	roleCache.Unlock()
+}
+
+// RoleExists reports whether a role with this name is defined
+func RoleExists(name string) bool {
+	var count int64
+	db.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// ListRoles returns every role with its permissions
+func ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := db.DB.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// GetRole returns a role with its permissions
+func GetRole(name string) (*models.Role, error) {
+	var role models.Role
+	if err := db.DB.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return &role, nil
+}
+
+// ValidatePermissions checks that every grant names a known permission and scope
+func ValidatePermissions(permissions []models.RolePermission) error {
+	for _, permission := range permissions {
+		if !IsKnownPermission(permission.Permission) {
+			return fmt.Errorf("unknown permission: %s", permission.Permission)
+		}
+		if permission.Scope != ScopeAll && permission.Scope != ScopeArea {
+			return fmt.Errorf("invalid scope for %s: must be 'all' or 'area'", permission.Permission)
+		}
+	}
+	return nil
+}
+
+// CreateRole stores a new custom role
+func CreateRole(name, description string, permissions []models.RolePermission) (*models.Role, error) {
+	if err := ValidatePermissions(permissions); err != nil {
+		return nil, err
+	}
+	if RoleExists(name) {
+		return nil, ErrRoleExists
+	}
+
+	role := models.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := db.DB.Create(&role).Error; err != nil {
+		return nil, err
+	}
+
+	invalidateRoles()
+	return &role, nil
+}
+
+// UpdateRole replaces a role's description and permissions
+func UpdateRole(name, description string, permissions []models.RolePermission) (*models.Role, error) {
+	if name == AdminRole {
+		return nil, ErrRoleLocked
+	}
+	if err := ValidatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := GetRole(name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Update("description", description).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for i := range permissions {
+			permissions[i].ID = 0
+			permissions[i].RoleID = role.ID
+		}
+		if len(permissions) > 0 {
+			return tx.Create(&permissions).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateRoles()
+	role.Description = description
+	role.Permissions = permissions
+	return role, nil
+}
+
+// roleReferences are the tables that name roles, with the condition matching one
+var roleReferences = []struct {
+	model interface{}
+	where string
+}{
+	{&models.User{}, "role = ?"},
+	{&models.AreaMembership{}, "role = ?"},
+	{&models.ResourceGrant{}, "grantee_type = '" + GranteeRole + "' AND grantee_id = ?"},
+	{&models.CameraRule{}, "subject_type = '" + GranteeRole + "' AND subject_id = ?"},
+	{&models.AccessScheduleAssignment{}, "subject_type = '" + GranteeRole + "' AND subject_id = ?"},
+}
+
+// DeleteRole removes a custom role that nothing refers to any more
+func DeleteRole(name string) error {
+	role, err := GetRole(name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	for _, reference := range roleReferences {
+		var count int64
+		if err := db.DB.Model(reference.model).Where(reference.where, name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleInUse
+		}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateRoles()
+	return nil
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // schedule timezones must load on hosts without a zoneinfo database
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// ErrOutsideSchedule is wrapped by the error CheckSchedule returns
+var ErrOutsideSchedule = errors.New("access is not allowed outside your scheduled hours")
+
+// scheduleCache holds the schedules attached to each user and role, keyed
+// "user:<id>" or "role:<name>", so per-request checks do not hit the database
+var scheduleCache struct {
+	sync.Mutex
+	schedules map[string][]models.AccessSchedule
+	loadedAt  time.Time
+}
+
+// schedulesFor returns the schedules attached to a subject, reloading the
+// cache when stale. If the reload fails the previous schedules are kept.
+func schedulesFor(subjectType, subjectID string) []models.AccessSchedule {
+	scheduleCache.Lock()
+	defer scheduleCache.Unlock()
+
+	if scheduleCache.schedules == nil || time.Since(scheduleCache.loadedAt) >= time.Duration(config.ROLE_CACHE_SECONDS)*time.Second {
+		var records []models.AccessSchedule
+		if err := db.DB.Preload("Windows").Preload("Holidays").Preload("Assignments").Find(&records).Error; err == nil {
+			schedules := make(map[string][]models.AccessSchedule)
+			for _, schedule := range records {
+				for _, assignment := range schedule.Assignments {
+					key := assignment.SubjectType + ":" + assignment.SubjectID
+					schedules[key] = append(schedules[key], schedule)
+				}
+			}
+			scheduleCache.schedules = schedules
+			scheduleCache.loadedAt = time.Now()
+		}
+	}
+
+	return scheduleCache.schedules[subjectType+":"+subjectID]
+}
+
+// InvalidateSchedules makes the next check reload schedules from the database
+func InvalidateSchedules() {
+	scheduleCache.Lock()
+	scheduleCache.loadedAt = time.Time{}
+	scheduleCache.Unlock()
+}
+
+// CheckSchedule returns an error wrapping ErrOutsideSchedule when user may
+// not log in or use a session at now. Schedules attached to the user replace
+// those of their role; with several, any one allowing access is enough.
+// Users without a schedule, and roles holding every permission, are never limited.
+func CheckSchedule(user *models.User, now time.Time) error {
+	if hasEverything(roleGrants(user.Role)) {
+		return nil
+	}
+
+	schedules := schedulesFor(GranteeUser, strconv.FormatUint(uint64(user.ID), 10))
+	if len(schedules) == 0 {
+		schedules = schedulesFor(GranteeRole, user.Role)
+	}
+	if len(schedules) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(schedules))
+	for i, schedule := range schedules {
+		if ScheduleAllows(schedule, now) {
+			return nil
+		}
+		names[i] = fmt.Sprintf("%s (%s)", schedule.Name, schedule.Timezone)
+	}
+	return fmt.Errorf("%w: %s", ErrOutsideSchedule, strings.Join(names, ", "))
+}
+
+// ScheduleAllows reports whether schedule allows access at now. A holiday on
+// the local date replaces that day's windows, including the part of an
+// overnight window carried over from the day before.
+func ScheduleAllows(schedule models.AccessSchedule, now time.Time) bool {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+
+	date := local.Format("2006-01-02")
+	for _, holiday := range schedule.Holidays {
+		if holiday.Date != date {
+			continue
+		}
+		if holiday.Start == "" {
+			return false
+		}
+		start, _ := ParseClock(holiday.Start)
+		end, _ := ParseClock(holiday.End)
+		return minute >= start && minute < end
+	}
+
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+	for _, window := range schedule.Windows {
+		start, err := ParseClock(window.Start)
+		if err != nil {
+			continue
+		}
+		end, err := ParseClock(window.End)
+		if err != nil {
+			continue
+		}
+
+		if end > start {
+			if window.Weekday == today && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		// Overnight: from start until midnight, then until end the next day
+		if (window.Weekday == today && minute >= start) || (window.Weekday == yesterday && minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseClock returns the minutes after midnight of an "HH:MM" time. "24:00"
+// is accepted as the end of the day.
+func ParseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || hours < 0 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", value)
+	}
+	return hours*60 + minutes, nil
+}
@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"fmt"
+
+	"go-auth/models"
+)
+
+// hasEverything reports whether a role holds every permission everywhere
+func hasEverything(grants map[string]string) bool {
+	return grants[AllPermissions] == ScopeAll
+}
+
+// scopeRank orders scopes from narrowest to widest
+var scopeRank = map[string]int{ScopeNone: 0, ScopeArea: 1, ScopeAll: 2}
+
+// RoleRank orders roles by how much they grant, so the most privileged of
+// several mapped roles can be picked: the scope rank of every permission,
+// summed. Roles that do not exist rank 0.
+func RoleRank(name string) int {
+	grants := roleGrants(name)
+	rank := 0
+	for _, permission := range Permissions {
+		held := scopeRank[grants[AllPermissions]]
+		if scope := scopeRank[grants[permission]]; scope > held {
+			held = scope
+		}
+		rank += held
+	}
+	return rank
+}
+
+// covers reports whether grants a include every permission in b at the same or a wider scope
+func covers(a, b map[string]string) bool {
+	for permission, scope := range b {
+		held := scopeRank[a[AllPermissions]]
+		if rank := scopeRank[a[permission]]; rank > held {
+			held = rank
+		}
+		if held < scopeRank[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// roleBelow reports whether role grants strictly less than actorRole. A role
+// can only hand out roles below its own, so managing users never escalates.
+func roleBelow(actorRole, role string) bool {
+	actor, target := roleGrants(actorRole), roleGrants(role)
+	return covers(actor, target) && !covers(target, actor)
+}
+
+// belowAnyOf reports whether role is below at least one of roles
+func belowAnyOf(roles []string, role string) bool {
+	for _, actorRole := range roles {
+		if roleBelow(actorRole, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAssignRole checks that actor may give role to a user in groupID, either
+// as their home role or as an area membership
+func CanAssignRole(actor *models.User, role string, groupID int) error {
+	if err := Authorize(actor, UserManage, InArea(groupID)); err != nil {
+		return err
+	}
+	if hasEverything(roleGrants(actor.Role)) {
+		return nil
+	}
+	if !belowAnyOf(rolesIn(actor, groupID, UserManage), role) {
+		return fmt.Errorf("%w: you cannot assign the %s role", ErrForbidden, role)
+	}
+	return nil
+}
+
+// CanManageUser checks that actor may change or remove target: target's home
+// area must be one actor manages, and every role target holds, including
+// area memberships, must be below actor's role there
+func CanManageUser(actor, target *models.User) error {
+	if err := Authorize(actor, UserManage, InArea(target.GroupId)); err != nil {
+		return err
+	}
+	return outranks(actor, target, UserManage, "manage")
+}
+
+// CanImpersonate checks that actor may act as target. As with managing
+// users, every role target holds must be below actor's role in target's area,
+// so impersonation never gains rights actor does not have.
+func CanImpersonate(actor, target *models.User) error {
+	if err := Authorize(actor, UserImpersonate, InArea(target.GroupId)); err != nil {
+		return err
+	}
+	return outranks(actor, target, UserImpersonate, "impersonate")
+}
+
+// outranks checks that every role target holds, including area memberships,
+// is below one of actor's roles granting action in target's home area. verb
+// names the refused action in the error.
+func outranks(actor, target *models.User, action, verb string) error {
+	if hasEverything(roleGrants(actor.Role)) {
+		return nil
+	}
+
+	actorRoles := rolesIn(actor, target.GroupId, action)
+	targetRoles := []string{target.Role}
+	for _, membership := range Memberships(target) {
+		targetRoles = append(targetRoles, membership.Role)
+	}
+	for _, role := range targetRoles {
+		if !belowAnyOf(actorRoles, role) {
+			return fmt.Errorf("%w: you cannot %s users with the %s role", ErrForbidden, verb, role)
+		}
+	}
+	return nil
+}
+
+// ManageableRoles returns the names of the roles actor may assign in an area
+// and whose holders there actor may see and manage; nil means every role
+func ManageableRoles(actor *models.User, groupID int) ([]string, error) {
+	if hasEverything(roleGrants(actor.Role)) {
+		return nil, nil
+	}
+
+	roles, err := ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	actorRoles := rolesIn(actor, groupID, UserManage)
+	names := []string{}
+	for _, role := range roles {
+		if belowAnyOf(actorRoles, role.Name) {
+			names = append(names, role.Name)
+		}
+	}
+	return names, nil
+}
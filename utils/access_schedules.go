@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the access schedule store
+var (
+	ErrScheduleNotFound  = errors.New("access schedule not found")
+	ErrScheduleNameTaken = errors.New("an access schedule with this name already exists")
+)
+
+// ListAccessSchedules returns every schedule with its windows, holidays and assignments
+func ListAccessSchedules() ([]models.AccessSchedule, error) {
+	schedules := []models.AccessSchedule{}
+	err := db.DB.Preload("Windows").Preload("Holidays").Preload("Assignments").
+		Order("name").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetAccessSchedule returns a schedule with its windows, holidays and assignments
+func GetAccessSchedule(id uint) (*models.AccessSchedule, error) {
+	var schedule models.AccessSchedule
+	err := db.DB.Preload("Windows").Preload("Holidays").Preload("Assignments").First(&schedule, id).Error
+	if err != nil {
+		return nil, ErrScheduleNotFound
+	}
+	return &schedule, nil
+}
+
+// ValidateAccessSchedule checks a schedule's timezone, times, dates and
+// assignments before it is stored
+func ValidateAccessSchedule(schedule *models.AccessSchedule) error {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	if schedule.Name == "" || len(schedule.Name) > 100 {
+		return fmt.Errorf("name is required (max 100 characters)")
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: %s", schedule.Timezone)
+	}
+
+	for _, window := range schedule.Windows {
+		if window.Weekday < 0 || window.Weekday > 6 {
+			return fmt.Errorf("weekday must be 0 (Sunday) to 6 (Saturday)")
+		}
+		if _, err := policy.ParseClock(window.Start); err != nil {
+			return err
+		}
+		if _, err := policy.ParseClock(window.End); err != nil {
+			return err
+		}
+	}
+
+	for _, holiday := range schedule.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+			return fmt.Errorf("invalid holiday date %q: use YYYY-MM-DD", holiday.Date)
+		}
+		if holiday.Start == "" && holiday.End == "" {
+			continue
+		}
+		start, err := policy.ParseClock(holiday.Start)
+		if err != nil {
+			return err
+		}
+		end, err := policy.ParseClock(holiday.End)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return fmt.Errorf("holiday %s must end after it starts", holiday.Date)
+		}
+	}
+
+	for _, assignment := range schedule.Assignments {
+		if assignment.SubjectType != policy.GranteeUser && assignment.SubjectType != policy.GranteeRole {
+			return fmt.Errorf("subjectType must be 'user' or 'role'")
+		}
+		if err := validateSubject(assignment.SubjectType, assignment.SubjectID, "subject"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleNameTaken reports whether another schedule already uses name
+func scheduleNameTaken(name string, exceptID uint) bool {
+	var count int64
+	db.DB.Model(&models.AccessSchedule{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count)
+	return count > 0
+}
+
+// CreateAccessSchedule stores a new schedule with its windows, holidays and assignments
+func CreateAccessSchedule(schedule *models.AccessSchedule) error {
+	if scheduleNameTaken(schedule.Name, 0) {
+		return ErrScheduleNameTaken
+	}
+	if err := db.DB.Create(schedule).Error; err != nil {
+		return err
+	}
+	policy.InvalidateSchedules()
+	return nil
+}
+
+// UpdateAccessSchedule replaces a schedule's details, windows, holidays and assignments
+func UpdateAccessSchedule(schedule *models.AccessSchedule) error {
+	if scheduleNameTaken(schedule.Name, schedule.ID) {
+		return ErrScheduleNameTaken
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.AccessSchedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+			"name":        schedule.Name,
+			"description": schedule.Description,
+			"timezone":    schedule.Timezone,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, child := range []interface{}{&models.AccessWindow{}, &models.AccessHoliday{}, &models.AccessScheduleAssignment{}} {
+			if err := tx.Where("schedule_id = ?", schedule.ID).Delete(child).Error; err != nil {
+				return err
+			}
+		}
+		for i := range schedule.Windows {
+			schedule.Windows[i].ID = 0
+			schedule.Windows[i].ScheduleID = schedule.ID
+		}
+		for i := range schedule.Holidays {
+			schedule.Holidays[i].ID = 0
+			schedule.Holidays[i].ScheduleID = schedule.ID
+		}
+		for i := range schedule.Assignments {
+			schedule.Assignments[i].ID = 0
+			schedule.Assignments[i].ScheduleID = schedule.ID
+		}
+		if len(schedule.Windows) > 0 {
+			if err := tx.Create(&schedule.Windows).Error; err != nil {
+				return err
+			}
+		}
+		if len(schedule.Holidays) > 0 {
+			if err := tx.Create(&schedule.Holidays).Error; err != nil {
+				return err
+			}
+		}
+		if len(schedule.Assignments) > 0 {
+			return tx.Create(&schedule.Assignments).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	policy.InvalidateSchedules()
+	return nil
+}
+
+// DeleteAccessSchedule removes a schedule, lifting it from everyone it was attached to
+func DeleteAccessSchedule(id uint) error {
+	if _, err := GetAccessSchedule(id); err != nil {
+		return err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, child := range []interface{}{&models.AccessWindow{}, &models.AccessHoliday{}, &models.AccessScheduleAssignment{}} {
+			if err := tx.Where("schedule_id = ?", id).Delete(child).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&models.AccessSchedule{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	policy.InvalidateSchedules()
+	return nil
+}
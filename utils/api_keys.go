@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+)
+
+const apiKeyPrefix = "vms_"
+
+// ErrInsufficientScope is returned when an API key is valid but not allowed on the route
+var ErrInsufficientScope = errors.New("api key does not have the required scope")
+
+// ErrReadOnlySession is returned when a read-only session attempts a write
+var ErrReadOnlySession = errors.New("session is read-only")
+
+// GetBearerToken returns the token from an "Authorization: Bearer" header, if any
+func GetBearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// ValidateAPIKeyScopes checks that every scope is "<resource>:read" or "<resource>:write"
+func ValidateAPIKeyScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		resource, access, ok := strings.Cut(scope, ":")
+		if _, known := config.API_KEY_SCOPES[resource]; !ok || !known || (access != "read" && access != "write") {
+			return fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// CreateAPIKey generates a key for user and stores its hash. The raw key is
+// returned once and cannot be recovered afterwards.
+func CreateAPIKey(user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	prefix, err := GenerateRandomToken(4)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	rawKey := apiKeyPrefix + prefix + "_" + secret
+
+	key := models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   HashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.DB.Create(&key).Error; err != nil {
+		return "", nil, err
+	}
+
+	return rawKey, &key, nil
+}
+
+// GetUserAPIKeys lists the keys belonging to a user, newest first
+func GetUserAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey marks a key as revoked
+func RevokeAPIKey(id uint) error {
+	return db.DB.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// GetUserFromAPIKey resolves the owner of an API key and checks that the key's
+// scopes cover the request. The owner's role and area still apply as usual.
+func GetUserFromAPIKey(r *http.Request, rawKey string) (*models.User, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	var key models.APIKey
+	if err := db.DB.Where("key_hash = ?", HashToken(rawKey)).First(&key).Error; err != nil {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("api key revoked")
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, fmt.Errorf("api key expired")
+	}
+
+	if !apiKeyAllows(key.Scopes, r) {
+		return nil, ErrInsufficientScope
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, key.UserID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// The key acts as its owner, so it is limited to the owner's schedule
+	if err := policy.CheckSchedule(&user, now); err != nil {
+		return nil, err
+	}
+
+	user.Auth = &models.AuthContext{Method: "api_key"}
+
+	// Avoid a write on every request from busy clients
+	interval := time.Duration(config.API_KEY_LAST_USED_UPDATE_SECONDS) * time.Second
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= interval {
+		db.DB.Model(&key).Update("last_used_at", now)
+	}
+
+	return &user, nil
+}
+
+// apiKeyAllows reports whether scopes grant access to the request's route and method
+func apiKeyAllows(scopes []string, r *http.Request) bool {
+	access := "write"
+	if r.Method == http.MethodGet || r.Method == http.MethodOptions {
+		access = "read"
+	}
+
+	for resource, route := range config.API_KEY_SCOPES {
+		if r.URL.Path != route && !strings.HasPrefix(r.URL.Path, route+"/") {
+			continue
+		}
+		return containsString(scopes, resource+":"+access)
+	}
+
+	// Routes without a scope (account, password, key management) need a browser session
+	return false
+}
+
+// SendAuthError responds to a failed GetUserFromSession: 403 when the
+// credential is valid but not allowed to do this, 401 otherwise
+func SendAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInsufficientScope) {
+		SendError(w, "API key does not have the required scope", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrCSRFTokenInvalid) {
+		SendError(w, "Invalid or missing CSRF token", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrDeviceCredential) {
+		SendError(w, "Device credentials can only be used on /device endpoints", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrImpersonationRestricted) {
+		SendError(w, "This action is not allowed while impersonating", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrReadOnlySession) {
+		SendError(w, "This session is read-only", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, policy.ErrOutsideSchedule) {
+		SendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	SendError(w, "Unauthorized", http.StatusUnauthorized)
+}
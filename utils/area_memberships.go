@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// GetUserMemberships returns a user's extra area memberships
+func GetUserMemberships(userID uint) ([]models.AreaMembership, error) {
+	memberships := []models.AreaMembership{}
+	err := db.DB.Where("user_id = ?", userID).Order("group_id").Find(&memberships).Error
+	return memberships, err
+}
+
+// SetAreaMembership adds a user to an area with a role, or changes the role
+// of an existing membership
+func SetAreaMembership(userID uint, groupID int, areaName, role string) (*models.AreaMembership, error) {
+	var membership models.AreaMembership
+	err := db.DB.Where("user_id = ? AND group_id = ?", userID, groupID).First(&membership).Error
+	if err != nil {
+		membership = models.AreaMembership{
+			UserID:   userID,
+			GroupID:  groupID,
+			AreaName: areaName,
+			Role:     role,
+		}
+		return &membership, db.DB.Create(&membership).Error
+	}
+
+	membership.AreaName = areaName
+	membership.Role = role
+	return &membership, db.DB.Model(&membership).Updates(map[string]interface{}{
+		"area_name": areaName,
+		"role":      role,
+	}).Error
+}
+
+// RemoveAreaMembership deletes a membership outright so the user can be re-added later
+func RemoveAreaMembership(membershipID uint) error {
+	return db.DB.Unscoped().Delete(&models.AreaMembership{}, membershipID).Error
+}
+
+// RemoveUserMemberships deletes every membership of a user
+func RemoveUserMemberships(userID uint) error {
+	return db.DB.Unscoped().Where("user_id = ?", userID).Delete(&models.AreaMembership{}).Error
+}
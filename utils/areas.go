@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the area store
+var (
+	ErrAreaNotFound    = errors.New("area not found")
+	ErrAreaNameTaken   = errors.New("an area with this name already exists")
+	ErrAreaCycle       = errors.New("an area cannot be its own ancestor")
+	ErrAreaHasChildren = errors.New("area has child areas")
+	ErrAreaInUse       = errors.New("area still has users, view groups, maps, cameras or login links")
+)
+
+// areaNameTables hold a copy of the area name next to group_id
+var areaNameTables = []string{"users", "tokens", "view_groups", "custom_maps", "area_memberships", "cameras"}
+
+// ListAreas returns every area
+func ListAreas() ([]models.Area, error) {
+	areas := []models.Area{}
+	err := db.DB.Order("name").Find(&areas).Error
+	return areas, err
+}
+
+// GetArea returns an area by ID
+func GetArea(id int) (*models.Area, error) {
+	var area models.Area
+	if err := db.DB.First(&area, id).Error; err != nil {
+		return nil, ErrAreaNotFound
+	}
+	return &area, nil
+}
+
+// checkAreaParent verifies a parent exists and would not create a cycle
+func checkAreaParent(areaID int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	for id, steps := *parentID, 0; ; steps++ {
+		if id == areaID || steps > 1000 {
+			return ErrAreaCycle
+		}
+		parent, err := GetArea(id)
+		if err != nil {
+			return fmt.Errorf("parent %w", ErrAreaNotFound)
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		id = *parent.ParentID
+	}
+}
+
+// checkAreaName rejects a name used by another area
+func checkAreaName(areaID int, name string) error {
+	var count int64
+	db.DB.Model(&models.Area{}).Where("name = ? AND id <> ?", name, areaID).Count(&count)
+	if count > 0 {
+		return ErrAreaNameTaken
+	}
+	return nil
+}
+
+// CreateArea stores a new area
+func CreateArea(area *models.Area) error {
+	if err := checkAreaName(0, area.Name); err != nil {
+		return err
+	}
+	if err := checkAreaParent(0, area.ParentID); err != nil {
+		return err
+	}
+	if err := db.DB.Create(area).Error; err != nil {
+		return err
+	}
+	policy.InvalidateAreas()
+	return nil
+}
+
+// UpdateArea saves changes to an area. A new name is copied to every row
+// that stores the area name.
+func UpdateArea(area *models.Area, previousName string) error {
+	if err := checkAreaName(area.ID, area.Name); err != nil {
+		return err
+	}
+	if err := checkAreaParent(area.ID, area.ParentID); err != nil {
+		return err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(area).Select("name", "parent_id", "is_hq", "include_children").Updates(area).Error; err != nil {
+			return err
+		}
+		if area.Name == previousName {
+			return nil
+		}
+		for _, table := range areaNameTables {
+			if err := tx.Table(table).Where("group_id = ?", area.ID).Update("area_name", area.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	policy.InvalidateAreas()
+	return nil
+}
+
+// DeleteArea removes an area that has no children and nothing referencing it
+func DeleteArea(id int) error {
+	area, err := GetArea(id)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	db.DB.Model(&models.Area{}).Where("parent_id = ?", id).Count(&count)
+	if count > 0 {
+		return ErrAreaHasChildren
+	}
+	for _, table := range areaNameTables {
+		db.DB.Table(table).Where("group_id = ?", id).Count(&count)
+		if count > 0 {
+			return ErrAreaInUse
+		}
+	}
+
+	if err := db.DB.Delete(area).Error; err != nil {
+		return err
+	}
+	policy.InvalidateAreas()
+	return nil
+}
+
+// ResolveArea returns the area for a group ID, for validating requests and
+// filling in the canonical area name
+func ResolveArea(groupID int) (*models.Area, error) {
+	if groupID == 0 {
+		return nil, ErrAreaNotFound
+	}
+	return GetArea(groupID)
+}
+
+// EnsureArea returns the area for a group ID named by an external identity
+// provider, creating it if this is the first user from that area
+func EnsureArea(groupID int, name string) (*models.Area, error) {
+	if area, err := GetArea(groupID); err == nil {
+		return area, nil
+	}
+
+	if name == "" || checkAreaName(groupID, name) != nil {
+		name = fmt.Sprintf("Area %d", groupID)
+	}
+	area := models.Area{ID: groupID, Name: name}
+	if err := db.DB.Create(&area).Error; err != nil {
+		return nil, err
+	}
+	policy.InvalidateAreas()
+	return &area, nil
+}
+
+// BackfillAreas creates an area for every group ID already in use, named after
+// the area name stored with it, so foreign keys can be added to existing tables
+func BackfillAreas() error {
+	for _, table := range areaNameTables {
+		if !db.DB.Migrator().HasTable(table) {
+			continue
+		}
+
+		var rows []struct {
+			GroupID  int
+			AreaName string
+		}
+		err := db.DB.Table(table).
+			Select("group_id, MAX(area_name) AS area_name").
+			Where("group_id <> 0").
+			Group("group_id").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if _, err := EnsureArea(row.GroupID, row.AreaName); err != nil {
+				return fmt.Errorf("area %d: %v", row.GroupID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// Authenticator verifies a username and password against one identity source.
+// It returns ErrInvalidCredentials when the source does not accept the login,
+// or another error when the source could not be reached.
+type Authenticator interface {
+	Name() string
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// Authenticators returns the configured password backends in the order they are tried
+func Authenticators() []Authenticator {
+	var backends []Authenticator
+	if config.LDAP_ENABLED {
+		backends = append(backends, NewLDAPAuthenticatorFromConfig())
+	}
+	return append(backends, LocalAuthenticator{})
+}
+
+// LocalAuthenticator checks the password hashes stored on models.User
+type LocalAuthenticator struct{}
+
+// Name identifies the backend
+func (LocalAuthenticator) Name() string {
+	return "local"
+}
+
+// Authenticate verifies a local user's password
+func (LocalAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	// Find user in database
+	var user models.User
+	if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Accounts provisioned by an external provider have no local password
+	if user.AuthProvider != "" && user.AuthProvider != "local" {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Verify password
+	if !VerifyPassword(user.Password, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Upgrade legacy or outdated hashes while the plaintext is at hand; a
+	// failed upgrade is retried on the next login
+	if PasswordNeedsRehash(user.Password) {
+		rehashPassword(&user, password)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+)
+
+// ListCameraRules returns the camera rules, optionally only those on one camera
+func ListCameraRules(cameraID string) ([]models.CameraRule, error) {
+	rules := []models.CameraRule{}
+	query := db.DB.Order("camera_id, id")
+	if cameraID != "" {
+		query = query.Where("camera_id = ?", cameraID)
+	}
+	err := query.Find(&rules).Error
+	return rules, err
+}
+
+// ValidateCameraRule checks a rule names a camera, an existing subject and a known effect
+func ValidateCameraRule(rule *models.CameraRule) error {
+	rule.CameraID = strings.TrimSpace(rule.CameraID)
+	if rule.CameraID == "" {
+		return fmt.Errorf("cameraId is required")
+	}
+	if rule.Effect != policy.CameraAllow && rule.Effect != policy.CameraDeny {
+		return fmt.Errorf("effect must be 'allow' or 'deny'")
+	}
+	return validateSubject(rule.SubjectType, rule.SubjectID, "subject")
+}
+
+// CreateCameraRule stores a rule, replacing an existing rule on the same
+// camera for the same subject
+func CreateCameraRule(rule *models.CameraRule) error {
+	err := db.DB.Unscoped().
+		Where("camera_id = ? AND subject_type = ? AND subject_id = ?", rule.CameraID, rule.SubjectType, rule.SubjectID).
+		Delete(&models.CameraRule{}).Error
+	if err != nil {
+		return err
+	}
+	if err := db.DB.Create(rule).Error; err != nil {
+		return err
+	}
+	policy.InvalidateCameraRules()
+	return nil
+}
+
+// DeleteCameraRule removes one camera rule
+func DeleteCameraRule(id uint) (bool, error) {
+	result := db.DB.Unscoped().Delete(&models.CameraRule{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	policy.InvalidateCameraRules()
+	return result.RowsAffected > 0, nil
+}
+
+// FilterViewGroupCameras removes the cameras user may not see from a view
+// group about to be sent to them
+func FilterViewGroupCameras(user *models.User, viewGroup *models.ViewGroup) {
+	cameras := models.StringArray{}
+	for _, id := range viewGroup.Cameras {
+		if policy.CanViewCamera(user, id) {
+			cameras = append(cameras, id)
+		}
+	}
+	viewGroup.Cameras = cameras
+
+	metadata := models.CameraMetadataArray{}
+	for _, meta := range viewGroup.CamerasMetadata {
+		if policy.CanViewCamera(user, meta.ID) {
+			metadata = append(metadata, meta)
+		}
+	}
+	viewGroup.CamerasMetadata = metadata
+}
+
+// KeepHiddenViewGroupCameras adds back to an update the cameras of viewGroup
+// that user cannot see, so saving a filtered view group does not drop them.
+// Hidden cameras sent in the request are ignored.
+func KeepHiddenViewGroupCameras(user *models.User, viewGroup *models.ViewGroup, req *UpdateViewGroupRequest) {
+	if req.Cameras != nil {
+		cameras := []string{}
+		for _, id := range req.Cameras {
+			if policy.CanViewCamera(user, id) {
+				cameras = append(cameras, id)
+			}
+		}
+		for _, id := range viewGroup.Cameras {
+			if !policy.CanViewCamera(user, id) {
+				cameras = append(cameras, id)
+			}
+		}
+		req.Cameras = cameras
+	}
+
+	if req.CamerasMetadata != nil {
+		metadata := []CameraMetadataRequest{}
+		for _, meta := range req.CamerasMetadata {
+			if policy.CanViewCamera(user, meta.ID) {
+				metadata = append(metadata, meta)
+			}
+		}
+		for _, meta := range viewGroup.CamerasMetadata {
+			if !policy.CanViewCamera(user, meta.ID) {
+				metadata = append(metadata, CameraMetadataRequest{ID: meta.ID, Name: meta.Name, GroupID: meta.GroupID})
+			}
+		}
+		req.CamerasMetadata = metadata
+	}
+}
+
+// FilterCameraPositions returns the positions of cameras user may see
+func FilterCameraPositions(user *models.User, positions []models.CameraPosition) []models.CameraPosition {
+	visible := []models.CameraPosition{}
+	for _, position := range positions {
+		if policy.CanViewCamera(user, position.CameraID) {
+			visible = append(visible, position)
+		}
+	}
+	return visible
+}
+
+// HiddenCameraPositionIDs returns the IDs of a map's camera positions user
+// cannot see, which an update by user must leave in place
+func HiddenCameraPositionIDs(user *models.User, mapID uint) []uint {
+	var positions []models.CameraPosition
+	db.DB.Where("custom_map_id = ?", mapID).Find(&positions)
+
+	hidden := []uint{}
+	for _, position := range positions {
+		if !policy.CanViewCamera(user, position.CameraID) {
+			hidden = append(hidden, position.ID)
+		}
+	}
+	return hidden
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors returned by the camera registry
+var (
+	ErrCameraNotFound = errors.New("camera not found")
+	ErrCameraExists   = errors.New("a camera with this ID already exists")
+	ErrCameraDisabled = errors.New("camera is disabled")
+	ErrCameraInUse    = errors.New("camera is still used by view groups or maps")
+)
+
+// CameraSearch filters the camera list. Query matches the ID, name, location,
+// manufacturer or model.
+type CameraSearch struct {
+	Query   string
+	GroupID int
+	Enabled *bool
+}
+
+// cameraAreas returns the areas whose cameras user may use: those where they
+// can view view groups or maps
+func cameraAreas(user *models.User) policy.AreaScope {
+	viewGroups := policy.Areas(user, policy.ViewGroupView)
+	maps := policy.Areas(user, policy.MapView)
+	if viewGroups.All || maps.All {
+		return policy.AreaScope{All: true}
+	}
+
+	areas := viewGroups
+	for _, id := range maps.GroupIDs {
+		if !areas.Allows(id) {
+			areas.GroupIDs = append(areas.GroupIDs, id)
+		}
+	}
+	return areas
+}
+
+// CanUseCamera checks that user may see camera and place it in view groups
+// and maps: it must be in an area they can view and not hidden by camera rules
+func CanUseCamera(user *models.User, camera *models.Camera) error {
+	if !cameraAreas(user).Allows(camera.GroupID) || !policy.CanViewCamera(user, camera.ID) {
+		return fmt.Errorf("%w: you cannot use camera %s", policy.ErrForbidden, camera.ID)
+	}
+	return nil
+}
+
+// CanManageCamera checks that user may register, change or remove cameras in an area
+func CanManageCamera(user *models.User, groupID int) error {
+	return policy.Authorize(user, policy.CameraManage, policy.InArea(groupID))
+}
+
+// SearchCameras returns the cameras user may use that match search
+func SearchCameras(user *models.User, search CameraSearch) ([]models.Camera, error) {
+	cameras := []models.Camera{}
+	query := db.DB.Order("name")
+
+	areas := cameraAreas(user)
+	if areas.None() {
+		return cameras, nil
+	}
+	if !areas.All {
+		query = query.Where("group_id IN ?", areas.GroupIDs)
+	}
+
+	if search.Query != "" {
+		like := "%" + search.Query + "%"
+		query = query.Where(db.DB.Where("id LIKE ?", like).Or("name LIKE ?", like).Or("location LIKE ?", like).
+			Or("manufacturer LIKE ?", like).Or("model LIKE ?", like))
+	}
+	if search.GroupID != 0 {
+		query = query.Where("group_id = ?", search.GroupID)
+	}
+	if search.Enabled != nil {
+		query = query.Where("enabled = ?", *search.Enabled)
+	}
+
+	var found []models.Camera
+	if err := query.Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, camera := range found {
+		if policy.CanViewCamera(user, camera.ID) {
+			cameras = append(cameras, camera)
+		}
+	}
+	return cameras, nil
+}
+
+// GetCamera returns a camera by ID
+func GetCamera(id string) (*models.Camera, error) {
+	var camera models.Camera
+	if err := db.DB.Where("id = ?", id).First(&camera).Error; err != nil {
+		return nil, ErrCameraNotFound
+	}
+	return &camera, nil
+}
+
+// CreateCamera registers a new camera
+func CreateCamera(camera *models.Camera) error {
+	if _, err := GetCamera(camera.ID); err == nil {
+		return ErrCameraExists
+	}
+	return db.DB.Create(camera).Error
+}
+
+// UpdateCamera saves changes to a camera. A new name or area is copied to the
+// map positions and view group metadata that store them.
+func UpdateCamera(camera *models.Camera, previous models.Camera) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(camera).Select("name", "group_id", "area_name", "stream_url", "snapshot_url",
+			"manufacturer", "model", "location", "enabled", "updated_by").Updates(camera).Error
+		if err != nil {
+			return err
+		}
+		if camera.Name == previous.Name && camera.GroupID == previous.GroupID {
+			return nil
+		}
+
+		if camera.Name != previous.Name {
+			err := tx.Model(&models.CameraPosition{}).Where("camera_id = ?", camera.ID).
+				Update("camera_name", camera.Name).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var viewGroups []models.ViewGroup
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("JSON_CONTAINS(cameras_metadata, JSON_OBJECT('id', ?))", camera.ID).Find(&viewGroups).Error
+		if err != nil {
+			return err
+		}
+		for _, viewGroup := range viewGroups {
+			for i := range viewGroup.CamerasMetadata {
+				if viewGroup.CamerasMetadata[i].ID == camera.ID {
+					viewGroup.CamerasMetadata[i].Name = camera.Name
+					viewGroup.CamerasMetadata[i].GroupID = camera.GroupID
+				}
+			}
+			err := tx.Model(&models.ViewGroup{}).Where("id = ?", viewGroup.ID).
+				Update("cameras_metadata", viewGroup.CamerasMetadata).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCamera removes a camera that no view group or map uses, with its visibility rules
+func DeleteCamera(id string) error {
+	camera, err := GetCamera(id)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	db.DB.Model(&models.CameraPosition{}).Where("camera_id = ?", id).Count(&count)
+	if count > 0 {
+		return ErrCameraInUse
+	}
+	db.DB.Model(&models.ViewGroup{}).Where(db.DB.Where("JSON_CONTAINS(cameras, JSON_QUOTE(?))", id).
+		Or("JSON_CONTAINS(cameras_metadata, JSON_OBJECT('id', ?))", id)).Count(&count)
+	if count > 0 {
+		return ErrCameraInUse
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("camera_id = ?", id).Delete(&models.CameraRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(camera).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	policy.InvalidateCameraRules()
+	return nil
+}
+
+// CheckNewCameras validates the cameras being added to a view group or map:
+// each must be registered, enabled and usable by user. IDs in existing are
+// already there and are not checked again, so cameras placed before the
+// registry existed keep working.
+func CheckNewCameras(user *models.User, ids []string, existing []string) error {
+	known := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+
+	added := []string{}
+	for _, id := range ids {
+		if !known[id] {
+			known[id] = true
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	var cameras []models.Camera
+	if err := db.DB.Where("id IN ?", added).Find(&cameras).Error; err != nil {
+		return err
+	}
+	byID := make(map[string]models.Camera, len(cameras))
+	for _, camera := range cameras {
+		byID[camera.ID] = camera
+	}
+
+	for _, id := range added {
+		camera, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrCameraNotFound, id)
+		}
+		if !camera.Enabled {
+			return fmt.Errorf("%w: %s", ErrCameraDisabled, id)
+		}
+		if err := CanUseCamera(user, &camera); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequestCameraIDs returns every camera ID a view group request refers to,
+// in Cameras or in CamerasMetadata
+func RequestCameraIDs(cameras []string, metadata []CameraMetadataRequest) []string {
+	ids := append([]string{}, cameras...)
+	for _, meta := range metadata {
+		ids = append(ids, meta.ID)
+	}
+	return ids
+}
+
+// MapCameraIDs returns the IDs of the cameras placed on a map
+func MapCameraIDs(mapID uint) []string {
+	ids := []string{}
+	db.DB.Model(&models.CameraPosition{}).Where("custom_map_id = ?", mapID).Pluck("camera_id", &ids)
+	return ids
+}
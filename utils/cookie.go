@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net/http"
+	"time"
 
 	"go-auth/config"
 )
@@ -15,7 +16,7 @@ func SetSessionCookie(w http.ResponseWriter, token string) {
 		HttpOnly: true,
 		Secure:   false, // Set to true in production with HTTPS
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   60 * 60 * 24 * 365 * config.TOKEN_EXPIRY_YEARS,
+		MaxAge:   60 * config.ACCESS_TOKEN_EXPIRY_MINUTES,
 	}
 	http.SetCookie(w, cookie)
 }
@@ -37,4 +38,165 @@ func ClearSessionCookie(w http.ResponseWriter) {
 // GetSessionCookie retrieves the session cookie from request
 func GetSessionCookie(r *http.Request) (*http.Cookie, error) {
 	return r.Cookie("next-auth.session-token")
-}
\ No newline at end of file
+}
+
+// SetRefreshCookie sets the opaque refresh token cookie
+func SetRefreshCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	cookie := &http.Cookie{
+		Name:     "vms.refresh-token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearRefreshCookie clears the refresh token cookie (for logout)
+func ClearRefreshCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "vms.refresh-token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1, // Delete cookie
+	}
+	http.SetCookie(w, cookie)
+}
+
+// GetRefreshCookie retrieves the refresh token cookie from request
+func GetRefreshCookie(r *http.Request) (*http.Cookie, error) {
+	return r.Cookie("vms.refresh-token")
+}
+
+// SetMFACookie sets the pending two-factor login cookie used by the form login flow
+func SetMFACookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     "vms.mfa-pending",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   60 * config.MFA_CHALLENGE_EXPIRY_MINUTES,
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearMFACookie clears the pending two-factor login cookie
+func ClearMFACookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "vms.mfa-pending",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1, // Delete cookie
+	}
+	http.SetCookie(w, cookie)
+}
+
+// GetMFACookie retrieves the pending two-factor login cookie from request
+func GetMFACookie(r *http.Request) (*http.Cookie, error) {
+	return r.Cookie("vms.mfa-pending")
+}
+
+// SetPasswordChangeCookie sets the pending password-change cookie used by the form login flow
+func SetPasswordChangeCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     "vms.password-change",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   60 * config.PASSWORD_CHANGE_TOKEN_EXPIRY_MINUTES,
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearPasswordChangeCookie clears the pending password-change cookie
+func ClearPasswordChangeCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "vms.password-change",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1, // Delete cookie
+	}
+	http.SetCookie(w, cookie)
+}
+
+// GetPasswordChangeCookie retrieves the pending password-change cookie from request
+func GetPasswordChangeCookie(r *http.Request) (*http.Cookie, error) {
+	return r.Cookie("vms.password-change")
+}
+
+// SetOIDCStateCookie sets the signed state/nonce/PKCE cookie for an SSO login in progress
+func SetOIDCStateCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     "vms.oidc-state",
+		Value:    token,
+		Path:     "/oidc",
+		HttpOnly: true,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   60 * config.OIDC_STATE_EXPIRY_MINUTES,
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearOIDCStateCookie clears the SSO state cookie
+func ClearOIDCStateCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "vms.oidc-state",
+		Value:    "",
+		Path:     "/oidc",
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1, // Delete cookie
+	}
+	http.SetCookie(w, cookie)
+}
+
+// GetOIDCStateCookie retrieves the SSO state cookie from request
+func GetOIDCStateCookie(r *http.Request) (*http.Cookie, error) {
+	return r.Cookie("vms.oidc-state")
+}
+
+// SetCSRFCookie sets the CSRF token cookie. It is readable by the frontend,
+// which echoes it in the X-CSRF-Token header on mutating requests.
+func SetCSRFCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	cookie := &http.Cookie{
+		Name:     "vms.csrf-token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: false,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearCSRFCookie clears the CSRF token cookie (for logout)
+func ClearCSRFCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "vms.csrf-token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: false,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1, // Delete cookie
+	}
+	http.SetCookie(w, cookie)
+}
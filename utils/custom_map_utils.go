@@ -1,9 +1,9 @@
 package utils
 
 import (
-	"fmt"
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 )
 
 // GetCustomMapsByUser retrieves custom maps based on user role and permissions
@@ -11,8 +11,14 @@ func GetCustomMapsByUser(user *models.User) ([]models.CustomMap, error) {
 	var customMaps []models.CustomMap
 	query := db.DB.Order("created_at DESC")
 
-	if user.Role != "admin" {
-		query = query.Where("group_id = ?", user.GroupId)
+	// Maps in the user's areas plus any shared with them
+	areas := policy.Areas(user, policy.MapView)
+	if !areas.All {
+		shared := policy.GrantedResourceIDs(user, policy.ResourceCustomMap, policy.MapView)
+		if areas.None() && len(shared) == 0 {
+			return customMaps, nil
+		}
+		query = query.Where(db.DB.Where("group_id IN ?", areas.GroupIDs).Or("id IN ?", shared))
 	}
 
 	if err := query.Find(&customMaps).Error; err != nil {
@@ -22,41 +28,28 @@ func GetCustomMapsByUser(user *models.User) ([]models.CustomMap, error) {
 	return customMaps, nil
 }
 
-// CanCreateCustomMap checks if user can create a custom map for target area
-func CanCreateCustomMap(user *models.User, targetGroupId int) error {
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic users cannot create custom maps")
-	}
+// CanViewCustomMap checks if user can see a custom map
+func CanViewCustomMap(user *models.User, customMap *models.CustomMap) error {
+	return policy.Authorize(user, policy.MapView, policy.CustomMap(customMap))
+}
 
-	if user.Role == "Area Admin" && user.GroupId != targetGroupId {
-		return fmt.Errorf("area admin can only create maps for their own area")
-	}
+// CanShareCustomMap checks if user can manage a custom map's grants. Only
+// users whose role can update it may share it; a grant cannot be re-shared.
+func CanShareCustomMap(user *models.User, customMap *models.CustomMap) error {
+	return policy.Authorize(user, policy.MapUpdate, policy.InArea(customMap.GroupID))
+}
 
-	return nil
+// CanCreateCustomMap checks if user can create a custom map for target area
+func CanCreateCustomMap(user *models.User, targetGroupId int) error {
+	return policy.Authorize(user, policy.MapCreate, policy.InArea(targetGroupId))
 }
 
 // CanUpdateCustomMap checks if user can update a custom map
 func CanUpdateCustomMap(user *models.User, customMap *models.CustomMap) error {
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic users cannot update custom maps")
-	}
-
-	if user.Role == "Area Admin" && user.GroupId != customMap.GroupID {
-		return fmt.Errorf("area admin can only update maps in their own area")
-	}
-
-	return nil
+	return policy.Authorize(user, policy.MapUpdate, policy.CustomMap(customMap))
 }
 
 // CanDeleteCustomMap checks if user can delete a custom map
 func CanDeleteCustomMap(user *models.User, customMap *models.CustomMap) error {
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic users cannot delete custom maps")
-	}
-
-	if user.Role == "Area Admin" && user.GroupId != customMap.GroupID {
-		return fmt.Errorf("area admin can only delete maps in their own area")
-	}
-
-	return nil
+	return policy.Authorize(user, policy.MapDelete, policy.CustomMap(customMap))
 }
\ No newline at end of file
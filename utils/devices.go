@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+)
+
+const deviceCredentialPrefix = "vmsdev_"
+
+// ErrDeviceCredential is returned when a device credential is used outside the /device endpoints
+var ErrDeviceCredential = errors.New("device credentials can only be used on device endpoints")
+
+// IsDeviceCredential reports whether a bearer token is a device credential
+func IsDeviceCredential(token string) bool {
+	return strings.HasPrefix(token, deviceCredentialPrefix)
+}
+
+// EnrollDevice registers a display for viewGroup. The raw credential is
+// returned once and cannot be recovered afterwards.
+func EnrollDevice(name string, viewGroup *models.ViewGroup, enrolledBy string) (string, *models.Device, error) {
+	prefix, err := GenerateRandomToken(4)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	credential := deviceCredentialPrefix + prefix + "_" + secret
+
+	device := models.Device{
+		Name:           name,
+		ViewGroupID:    viewGroup.ID,
+		GroupId:        viewGroup.GroupID,
+		Prefix:         prefix,
+		CredentialHash: HashToken(credential),
+		EnrolledBy:     enrolledBy,
+	}
+	if err := db.DB.Create(&device).Error; err != nil {
+		return "", nil, err
+	}
+
+	return credential, &device, nil
+}
+
+// GetDeviceFromRequest authenticates a device by its bearer credential and
+// records that it was seen
+func GetDeviceFromRequest(r *http.Request) (*models.Device, error) {
+	credential := GetBearerToken(r)
+	if !IsDeviceCredential(credential) {
+		return nil, fmt.Errorf("missing device credential")
+	}
+
+	var device models.Device
+	if err := db.DB.Where("credential_hash = ?", HashToken(credential)).First(&device).Error; err != nil {
+		return nil, fmt.Errorf("invalid device credential")
+	}
+	if device.RevokedAt != nil {
+		return nil, fmt.Errorf("device revoked")
+	}
+
+	now := time.Now()
+	ip := ClientIP(r)
+	db.DB.Model(&device).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"last_seen_ip": ip,
+	})
+	device.LastSeenAt = &now
+	device.LastSeenIP = ip
+	device.Online = true
+
+	return &device, nil
+}
+
+// ListDevices returns the devices user may manage, newest first, with their
+// online status
+func ListDevices(user *models.User) ([]models.Device, error) {
+	var devices []models.Device
+	query := db.DB.Order("created_at DESC")
+
+	areas := policy.Areas(user, policy.DeviceManage)
+	if !areas.All {
+		if areas.None() {
+			return devices, nil
+		}
+		query = query.Where("group_id IN ?", areas.GroupIDs)
+	}
+
+	if err := query.Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		SetDeviceOnline(&devices[i])
+	}
+	return devices, nil
+}
+
+// SetDeviceOnline fills in the computed Online flag from the last heartbeat
+func SetDeviceOnline(device *models.Device) {
+	cutoff := time.Now().Add(-time.Duration(config.DEVICE_OFFLINE_AFTER_SECONDS) * time.Second)
+	device.Online = device.RevokedAt == nil && device.LastSeenAt != nil && device.LastSeenAt.After(cutoff)
+}
+
+// AssignDeviceViewGroup points a device at a different view group
+func AssignDeviceViewGroup(device *models.Device, viewGroup *models.ViewGroup) error {
+	device.ViewGroupID = viewGroup.ID
+	device.GroupId = viewGroup.GroupID
+	return db.DB.Model(device).Updates(map[string]interface{}{
+		"view_group_id": viewGroup.ID,
+		"group_id":      viewGroup.GroupID,
+	}).Error
+}
+
+// DeviceViewer is who camera rules are checked against for a device: someone
+// in the device's area with no role, so only area rules apply to it
+func DeviceViewer(device *models.Device) *models.User {
+	return &models.User{GroupId: device.GroupId, Memberships: []models.AreaMembership{}}
+}
+
+// RevokeDevice disables a device credential
+func RevokeDevice(device *models.Device) error {
+	now := time.Now()
+	device.RevokedAt = &now
+	return db.DB.Model(device).Update("revoked_at", now).Error
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"go-auth/policy"
+)
+
+// roleRank ranks roles so the most privileged mapped role wins
+var roleRank = policy.RoleRank
+
+// ProvisionOIDCUser finds or just-in-time creates the local user for verified ID token
+// claims. Role and area are refreshed from the IdP on every login.
+func ProvisionOIDCUser(claims map[string]interface{}) (*models.User, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("id_token has no subject")
+	}
+
+	username, _ := claims[config.OIDC_USERNAME_CLAIM].(string)
+	if username == "" {
+		username, _ = claims["email"].(string)
+	}
+	if username == "" {
+		username = sub
+	}
+
+	groupId := claimInt(claims[config.OIDC_GROUP_ID_CLAIM])
+	if groupId == 0 {
+		groupId = config.OIDC_DEFAULT_GROUP_ID
+	}
+	if groupId == 0 {
+		return nil, fmt.Errorf("id_token has no %s claim", config.OIDC_GROUP_ID_CLAIM)
+	}
+
+	areaName, _ := claims[config.OIDC_AREA_NAME_CLAIM].(string)
+	role := MapOIDCRole(claimStrings(claims[config.OIDC_ROLE_CLAIM]))
+
+	return ProvisionExternalUser("oidc", sub, username, groupId, areaName, role)
+}
+
+// ProvisionExternalUser finds or just-in-time creates the local record for a user
+// authenticated by an external provider, refreshing role and area on every login
+func ProvisionExternalUser(provider, externalID, username string, groupId int, areaName, role string) (*models.User, error) {
+	// The provider's area becomes a local area the first time it is seen
+	area, err := EnsureArea(groupId, areaName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision area")
+	}
+	areaName = area.Name
+
+	var user models.User
+	err = db.DB.Where("auth_provider = ? AND external_id = ?", provider, externalID).First(&user).Error
+	if err != nil {
+		// Never attach an external identity to an existing account that only shares the username
+		var existing models.User
+		if db.DB.Unscoped().Where("username = ?", username).First(&existing).Error == nil {
+			return nil, fmt.Errorf("username %q is already used by another account", username)
+		}
+
+		user = models.User{
+			Username:     username,
+			GroupId:      groupId,
+			AreaName:     areaName,
+			Role:         role,
+			AuthProvider: provider,
+			ExternalID:   externalID,
+		}
+		if err := db.DB.Create(&user).Error; err != nil {
+			return nil, fmt.Errorf("failed to provision user")
+		}
+		return &user, nil
+	}
+
+	err = db.DB.Model(&user).Updates(map[string]interface{}{
+		"group_id":  groupId,
+		"area_name": areaName,
+		"role":      role,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user")
+	}
+	user.GroupId = groupId
+	user.AreaName = areaName
+	user.Role = role
+
+	return &user, nil
+}
+
+// MapOIDCRole picks the most privileged VMS role named by the IdP role/group values
+func MapOIDCRole(values []string) string {
+	best := config.OIDC_DEFAULT_ROLE
+	for _, value := range values {
+		role, ok := config.OIDC_ROLE_MAPPING[value]
+		if !ok {
+			if ValidateRole(value) != nil {
+				continue
+			}
+			role = value
+		}
+		if roleRank(role) > roleRank(best) {
+			best = role
+		}
+	}
+	return best
+}
+
+// claimInt reads a claim that may be a JSON number or a numeric string
+func claimInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
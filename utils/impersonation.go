@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// ErrImpersonationRestricted is returned when an impersonation session attempts a blocked action
+var ErrImpersonationRestricted = errors.New("action not allowed while impersonating")
+
+// impersonationBlockedPaths are never available to an impersonator, even with
+// destructive actions allowed, because they change the user's own credentials
+var impersonationBlockedPaths = []string{
+	"/me/password",
+	"/me/sessions",
+	"/mfa/",
+	"/api-keys",
+	"/tokens",
+}
+
+// StartImpersonation starts a short-lived session as target on behalf of
+// admin, replacing the admin's cookies. The admin's own session is kept and
+// resumed by StopImpersonation.
+func StartImpersonation(w http.ResponseWriter, r *http.Request, admin *models.User, target models.User, allowDestructive bool, reason string) (time.Time, error) {
+	adminID := admin.ID
+	parentID := admin.Auth.SessionID
+	session := models.Session{
+		LoginMethod:      LoginMethodImpersonation,
+		ExpiresAt:        time.Now().Add(time.Minute * config.IMPERSONATION_EXPIRY_MINUTES),
+		ImpersonatorID:   &adminID,
+		ImpersonatorName: admin.Username,
+		ParentSessionID:  &parentID,
+		AllowDestructive: allowDestructive,
+	}
+	expiresAt, err := startSession(w, r, target, &session)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	recordImpersonation(r, &session, "start", reason)
+
+	return expiresAt, nil
+}
+
+// StopImpersonation ends an impersonation session and restores the admin's
+// own session cookies
+func StopImpersonation(w http.ResponseWriter, r *http.Request, session *models.Session) error {
+	if err := RevokeSessionFamily(session.ID); err != nil {
+		return err
+	}
+	recordImpersonation(r, session, "stop", "")
+
+	if session.ParentSessionID == nil {
+		return errors.New("no session to return to")
+	}
+	_, err := ResumeSession(w, *session.ParentSessionID)
+	return err
+}
+
+// CheckImpersonation audits a request made with an impersonation session and
+// blocks credential changes, and any other mutation unless the admin allowed it
+func CheckImpersonation(r *http.Request, session *models.Session) error {
+	if session.ImpersonatorID == nil {
+		return nil
+	}
+
+	blocked := IsMutatingRequest(r) && !session.AllowDestructive
+	for _, path := range impersonationBlockedPaths {
+		if strings.HasPrefix(r.URL.Path, path) {
+			blocked = true
+		}
+	}
+
+	if blocked {
+		recordImpersonation(r, session, "blocked", "")
+		return ErrImpersonationRestricted
+	}
+
+	recordImpersonation(r, session, "request", "")
+	return nil
+}
+
+// GetImpersonationAudit returns the most recent audit records, optionally
+// filtered by impersonator or target user
+func GetImpersonationAudit(impersonatorID, targetUserID uint, limit int) ([]models.ImpersonationAudit, error) {
+	var records []models.ImpersonationAudit
+	query := db.DB.Order("id DESC").Limit(limit)
+	if impersonatorID != 0 {
+		query = query.Where("impersonator_id = ?", impersonatorID)
+	}
+	if targetUserID != 0 {
+		query = query.Where("target_user_id = ?", targetUserID)
+	}
+	err := query.Find(&records).Error
+	return records, err
+}
+
+// recordImpersonation writes one impersonation audit record
+func recordImpersonation(r *http.Request, session *models.Session, action, reason string) {
+	if session.ImpersonatorID == nil {
+		return
+	}
+
+	path := r.URL.Path
+	if len(path) > 512 {
+		path = path[:512]
+	}
+	if len(reason) > 512 {
+		reason = reason[:512]
+	}
+
+	db.DB.Create(&models.ImpersonationAudit{
+		SessionID:        session.ID,
+		ImpersonatorID:   *session.ImpersonatorID,
+		ImpersonatorName: session.ImpersonatorName,
+		TargetUserID:     session.UserID,
+		TargetUsername:   session.Username,
+		Action:           action,
+		Method:           r.Method,
+		Path:             path,
+		IPAddress:        ClientIP(r),
+		Reason:           reason,
+	})
+}
@@ -5,18 +5,23 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"go-auth/config"
+	"go-auth/db"
 	"go-auth/models"
 )
 
+// ErrChallengeUsed is returned when a single-use challenge token is presented again
+var ErrChallengeUsed = errors.New("token already used")
+
 // JWT structures
 type JWTHeader struct {
 	Alg string `json:"alg"`
 	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
 }
 
 type JWTPayload struct {
@@ -30,39 +35,21 @@ type JWTPayload struct {
 	Exp      int64  `json:"exp"`
 }
 
-// CreateSessionJWT creates a NextAuth compatible JWT for sessions
-func CreateSessionJWT(user models.User) (string, error) {
-	now := time.Now().Unix()
-	expiresAt := now + (60 * 60 * 24 * 365 * config.TOKEN_EXPIRY_YEARS)
-
+// CreateSessionJWT creates a NextAuth compatible access JWT for a session
+func CreateSessionJWT(user models.User, jti string, expiresAt time.Time) (string, error) {
 	payload := map[string]interface{}{
+		"jti":      jti,
 		"sub":      fmt.Sprintf("%d", user.ID),
 		"id":       fmt.Sprintf("%d", user.ID),
 		"username": user.Username,
 		"groupId":  user.GroupId,
 		"areaName": user.AreaName,
 		"role":     user.Role,
-		"iat":      now,
-		"exp":      expiresAt,
+		"iat":      time.Now().Unix(),
+		"exp":      expiresAt.Unix(),
 	}
 
-	header := JWTHeader{
-		Alg: "HS256",
-		Typ: "JWT",
-	}
-
-	headerJSON, _ := json.Marshal(header)
-	payloadJSON, _ := json.Marshal(payload)
-
-	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
-	payloadB64 := base64.RawURLEncoding.EncodeToString(payloadJSON)
-
-	message := headerB64 + "." + payloadB64
-	signature := CreateHMACSignature(message, config.SESSION_SECRET)
-
-	token := message + "." + signature
-
-	return token, nil
+	return signJWT(payload)
 }
 
 // CreateTokenJWT creates a JWT for token-based authentication
@@ -78,77 +65,132 @@ func CreateTokenJWT(user models.User, expiresAt time.Time) (string, error) {
 		Exp:      expiresAt.Unix(),
 	}
 
-	header := JWTHeader{
-		Alg: "HS256",
-		Typ: "JWT",
+	return signJWT(payload)
+}
+
+// VerifySessionJWT verifies and parses a session JWT
+func VerifySessionJWT(token string) (map[string]interface{}, error) {
+	payload, _, err := VerifySessionToken(token)
+	return payload, err
+}
+
+// VerifySessionToken verifies a session JWT and also returns its session record
+func VerifySessionToken(token string) (map[string]interface{}, *models.Session, error) {
+	payloadBytes, err := verifyJWT(token, "")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	headerJSON, _ := json.Marshal(header)
-	payloadJSON, _ := json.Marshal(payload)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return nil, nil, err
+	}
 
-	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
-	payloadB64 := base64.RawURLEncoding.EncodeToString(payloadJSON)
+	// Check expiry
+	if exp, ok := payload["exp"].(float64); ok {
+		if time.Now().Unix() > int64(exp) {
+			return nil, nil, fmt.Errorf("token expired")
+		}
+	}
 
-	message := headerB64 + "." + payloadB64
-	signature := CreateHMACSignature(message, config.JWT_SECRET)
+	// Check the session has not been revoked
+	jti, ok := payload["jti"].(string)
+	if !ok || jti == "" {
+		return nil, nil, fmt.Errorf("missing session id")
+	}
+	session, err := GetActiveSession(jti)
+	if err != nil {
+		return nil, nil, fmt.Errorf("session revoked")
+	}
 
-	token := message + "." + signature
+	return payload, session, nil
+}
 
-	return token, nil
+// VerifyTokenJWT verifies a login-link JWT signature. Links issued before the
+// key ring are still accepted through JWT_SECRET until LEGACY_TOKEN_CUTOFF.
+func VerifyTokenJWT(token string) (bool, error) {
+	if _, err := verifyJWT(token, config.JWT_SECRET); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// CreateNextAuthJWT creates a NextAuth compatible JWT from token record
-func CreateNextAuthJWT(tokenRecord models.Token) (string, error) {
-	now := time.Now().Unix()
-	expiresAt := now + (60 * 60 * 24 * 365 * config.TOKEN_EXPIRY_YEARS)
+// CreateHMACSignature creates an HMAC-SHA256 signature
+func CreateHMACSignature(message, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
 
-	payload := map[string]interface{}{
-		"sub":      fmt.Sprintf("%d", tokenRecord.UserID),
-		"id":       fmt.Sprintf("%d", tokenRecord.UserID),
-		"username": tokenRecord.Username,
-		"groupId":  tokenRecord.GroupId,
-		"areaName": tokenRecord.AreaName,
-		"role":     tokenRecord.Role,
-		"iat":      now,
-		"exp":      expiresAt,
+// CreateChallengeJWT creates a short-lived token for an unfinished login step (e.g. "mfa").
+// Its jti lets ConsumeChallengeJWT accept it only once.
+func CreateChallengeJWT(userID uint, purpose string, expiresAt time.Time) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
 	}
+	return CreatePurposeJWT(purpose, map[string]interface{}{
+		"sub": fmt.Sprintf("%d", userID),
+		"jti": jti,
+	}, expiresAt)
+}
 
-	header := JWTHeader{
-		Alg: "HS256",
-		Typ: "JWT",
+// ConsumeChallengeJWT marks a challenge token as used. Call it once the step
+// it guards has succeeded; a second call for the same token returns ErrChallengeUsed.
+func ConsumeChallengeJWT(token, purpose string) error {
+	payload, err := VerifyPurposeJWT(token, purpose)
+	if err != nil {
+		return err
+	}
+	jti, _ := payload["jti"].(string)
+	exp, _ := payload["exp"].(float64)
+	if jti == "" {
+		return fmt.Errorf("missing token id")
 	}
 
-	headerJSON, _ := json.Marshal(header)
-	payloadJSON, _ := json.Marshal(payload)
+	// Expired entries can no longer be replayed, so they are dropped as we go
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&models.UsedChallenge{})
 
-	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
-	payloadB64 := base64.RawURLEncoding.EncodeToString(payloadJSON)
+	used := models.UsedChallenge{JTI: jti, Purpose: purpose, ExpiresAt: time.Unix(int64(exp), 0)}
+	if err := db.DB.Create(&used).Error; err != nil {
+		return ErrChallengeUsed
+	}
+	return nil
+}
 
-	message := headerB64 + "." + payloadB64
-	signature := CreateHMACSignature(message, config.JWT_SECRET)
+// VerifyChallengeJWT verifies a challenge token for the given purpose and returns its user ID
+func VerifyChallengeJWT(token, purpose string) (uint, error) {
+	payload, err := VerifyPurposeJWT(token, purpose)
+	if err != nil {
+		return 0, err
+	}
 
-	token := message + "." + signature
+	sub, _ := payload["sub"].(string)
+	var userID uint
+	if _, err := fmt.Sscanf(sub, "%d", &userID); err != nil {
+		return 0, fmt.Errorf("invalid token subject")
+	}
 
-	return token, nil
+	return userID, nil
 }
 
-// VerifySessionJWT verifies and parses a session JWT
-func VerifySessionJWT(token string) (map[string]interface{}, error) {
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid token format")
+// CreatePurposeJWT creates a short-lived signed token that is only valid for one purpose
+func CreatePurposeJWT(purpose string, claims map[string]interface{}, expiresAt time.Time) (string, error) {
+	payload := map[string]interface{}{}
+	for k, v := range claims {
+		payload[k] = v
 	}
+	payload["purpose"] = purpose
+	payload["iat"] = time.Now().Unix()
+	payload["exp"] = expiresAt.Unix()
 
-	message := parts[0] + "." + parts[1]
-	signature := parts[2]
-
-	expectedSignature := CreateHMACSignature(message, config.SESSION_SECRET)
-	if signature != expectedSignature {
-		return nil, fmt.Errorf("invalid signature")
-	}
+	return signJWT(payload)
+}
 
-	// Decode payload
-	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+// VerifyPurposeJWT verifies a token created by CreatePurposeJWT and returns its claims.
+// Purpose tokens are only ever signed by the key ring.
+func VerifyPurposeJWT(token, purpose string) (map[string]interface{}, error) {
+	payloadBytes, err := verifyJWT(token, "")
 	if err != nil {
 		return nil, err
 	}
@@ -158,34 +200,12 @@ func VerifySessionJWT(token string) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	// Check expiry
-	if exp, ok := payload["exp"].(float64); ok {
-		if time.Now().Unix() > int64(exp) {
-			return nil, fmt.Errorf("token expired")
-		}
+	if p, _ := payload["purpose"].(string); p != purpose {
+		return nil, fmt.Errorf("invalid token purpose")
 	}
-
-	return payload, nil
-}
-
-// VerifyTokenJWT verifies a token JWT signature
-func VerifyTokenJWT(token string) (bool, error) {
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return false, fmt.Errorf("invalid token format")
+	if exp, ok := payload["exp"].(float64); !ok || time.Now().Unix() > int64(exp) {
+		return nil, fmt.Errorf("token expired")
 	}
 
-	message := parts[0] + "." + parts[1]
-	signature := parts[2]
-
-	expectedSignature := CreateHMACSignature(message, config.JWT_SECRET)
-
-	return signature == expectedSignature, nil
+	return payload, nil
 }
-
-// CreateHMACSignature creates an HMAC-SHA256 signature
-func CreateHMACSignature(message, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(message))
-	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
-}
\ No newline at end of file
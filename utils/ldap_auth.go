@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"go-auth/config"
+	"go-auth/models"
+)
+
+// LDAPAuthenticator binds against an LDAP / Active Directory server and maps
+// directory groups to VMS roles and areas
+type LDAPAuthenticator struct {
+	URL               string
+	StartTLS          bool
+	TLSConfig         *tls.Config
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	UserObjectClass   string
+	UsernameAttribute string
+	GroupAttribute    string
+	GroupIdAttribute  string
+	AreaNameAttribute string
+	RoleGroups        map[string]string
+	AreaGroups        map[string]config.LDAPArea
+	Timeout           time.Duration
+}
+
+// NewLDAPAuthenticatorFromConfig builds an LDAPAuthenticator from the config constants
+func NewLDAPAuthenticatorFromConfig() *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		URL:               config.LDAP_URL,
+		StartTLS:          config.LDAP_START_TLS,
+		TLSConfig:         &tls.Config{InsecureSkipVerify: config.LDAP_INSECURE_SKIP_VERIFY},
+		BindDN:            config.LDAP_BIND_DN,
+		BindPassword:      config.LDAP_BIND_PASSWORD,
+		BaseDN:            config.LDAP_BASE_DN,
+		UserObjectClass:   config.LDAP_USER_OBJECT_CLASS,
+		UsernameAttribute: config.LDAP_USERNAME_ATTRIBUTE,
+		GroupAttribute:    config.LDAP_GROUP_ATTRIBUTE,
+		GroupIdAttribute:  config.LDAP_GROUP_ID_ATTRIBUTE,
+		AreaNameAttribute: config.LDAP_AREA_NAME_ATTRIBUTE,
+		RoleGroups:        config.LDAP_ROLE_GROUPS,
+		AreaGroups:        config.LDAP_AREA_GROUPS,
+		Timeout:           time.Duration(config.LDAP_TIMEOUT_SECONDS) * time.Second,
+	}
+}
+
+// Name identifies the backend
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+// ldapIdentity is what the directory says about a user who bound successfully
+type ldapIdentity struct {
+	Role     string
+	GroupId  int
+	AreaName string
+}
+
+// Authenticate finds the user's entry with the service account, then binds as the user
+func (a *LDAPAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	identity, err := a.lookup(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return ProvisionExternalUser("ldap", strings.ToLower(username), username, identity.GroupId, identity.AreaName, identity.Role)
+}
+
+// lookup checks the password against the directory and maps the user's groups
+func (a *LDAPAuthenticator) lookup(username, password string) (*ldapIdentity, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldap unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	if a.StartTLS {
+		if err := conn.StartTLS(a.tlsConfig()); err != nil {
+			return nil, fmt.Errorf("ldap starttls failed: %v", err)
+		}
+	}
+
+	if err := conn.Bind(a.BindDN, a.BindPassword); err != nil {
+		return nil, fmt.Errorf("ldap service bind failed: %v", err)
+	}
+
+	attributes := []string{a.GroupAttribute}
+	if a.GroupIdAttribute != "" {
+		attributes = append(attributes, a.GroupIdAttribute)
+	}
+	if a.AreaNameAttribute != "" {
+		attributes = append(attributes, a.AreaNameAttribute)
+	}
+
+	filter := fmt.Sprintf("(&(objectClass=%s)(%s=%s))", ldap.EscapeFilter(a.UserObjectClass),
+		a.UsernameAttribute, ldap.EscapeFilter(username))
+	result, err := conn.Search(ldap.NewSearchRequest(a.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(a.Timeout.Seconds()), false, filter, attributes, nil))
+	if err != nil {
+		return nil, fmt.Errorf("ldap search failed: %v", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	// Bind refuses an empty password, which the directory would otherwise
+	// treat as an unauthenticated bind that always succeeds
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind failed: %v", err)
+	}
+
+	groups := entry.GetEqualFoldAttributeValues(a.GroupAttribute)
+
+	role, ok := a.mapRole(groups)
+	if !ok {
+		return nil, ErrInvalidCredentials // not in any VMS group
+	}
+
+	groupId, areaName := a.mapArea(entry, groups)
+	if groupId == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &ldapIdentity{Role: role, GroupId: groupId, AreaName: areaName}, nil
+}
+
+// dial connects to the ldap:// or ldaps:// URL with the configured timeout
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.Timeout}),
+		ldap.DialWithTLSConfig(a.tlsConfig()))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(a.Timeout)
+	return conn, nil
+}
+
+// tlsConfig returns a copy of the TLS config with the server name filled in
+func (a *LDAPAuthenticator) tlsConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if a.TLSConfig != nil {
+		cfg = a.TLSConfig.Clone()
+	}
+	if u, err := url.Parse(a.URL); err == nil && cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+	return cfg
+}
+
+// mapRole picks the most privileged role granted by the user's groups
+func (a *LDAPAuthenticator) mapRole(groups []string) (string, bool) {
+	best := ""
+	for _, group := range groups {
+		for dn, role := range a.RoleGroups {
+			if strings.EqualFold(dn, group) && roleRank(role) > roleRank(best) {
+				best = role
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// mapArea reads GroupId/AreaName from entry attributes, falling back to group mappings
+func (a *LDAPAuthenticator) mapArea(entry *ldap.Entry, groups []string) (int, string) {
+	if a.GroupIdAttribute != "" {
+		if groupId, err := strconv.Atoi(entry.GetEqualFoldAttributeValue(a.GroupIdAttribute)); err == nil && groupId != 0 {
+			areaName := ""
+			if a.AreaNameAttribute != "" {
+				areaName = entry.GetEqualFoldAttributeValue(a.AreaNameAttribute)
+			}
+			return groupId, areaName
+		}
+	}
+
+	for _, group := range groups {
+		for dn, area := range a.AreaGroups {
+			if strings.EqualFold(dn, group) {
+				return area.GroupId, area.AreaName
+			}
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"go-auth/config"
+)
+
+const (
+	testServiceDN       = "CN=vms-service,OU=Service Accounts,DC=example,DC=com"
+	testServicePassword = "service-secret"
+	testAdminsGroup     = "CN=VMS Admins,OU=Groups,DC=example,DC=com"
+	testGuardsGroup     = "CN=VMS Guards,OU=Groups,DC=example,DC=com"
+	testNorthGroup      = "CN=VMS Area North,OU=Groups,DC=example,DC=com"
+)
+
+// testLDAPEntry is a user in the in-process directory
+type testLDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// testLDAPServer is an in-process LDAP v3 directory that answers simple
+// binds and subtree searches with AND, equality and presence filters
+type testLDAPServer struct {
+	listener net.Listener
+	entries  []testLDAPEntry
+}
+
+func newTestLDAPServer(t *testing.T, entries ...testLDAPEntry) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testLDAPServer{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(op.Children[1].Data.String(), op.Children[2].Data.String())
+			conn.Write(testLDAPMessage(msgID, testLDAPResult(ldap.ApplicationBindResponse, code)))
+
+		case ldap.ApplicationSearchRequest:
+			for _, message := range s.search(msgID, op) {
+				conn.Write(message)
+			}
+
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *testLDAPServer) bind(dn, password string) int {
+	if dn == testServiceDN && password == testServicePassword {
+		return ldap.LDAPResultSuccess
+	}
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && password != "" && entry.Password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// search returns the encoded result entries followed by the done message
+func (s *testLDAPServer) search(msgID int64, op *ber.Packet) [][]byte {
+	baseDN := op.Children[0].Data.String()
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, attribute.Data.String())
+	}
+
+	var messages [][]byte
+	code := ldap.LDAPResultSuccess
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(baseDN)) || !testLDAPMatch(filter, entry) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(messages)) == sizeLimit {
+			code = ldap.LDAPResultSizeLimitExceeded
+			break
+		}
+		messages = append(messages, testLDAPMessage(msgID, testLDAPSearchEntry(entry, requested)))
+	}
+
+	return append(messages, testLDAPMessage(msgID, testLDAPResult(ldap.ApplicationSearchResultDone, code)))
+}
+
+func testLDAPMatch(filter *ber.Packet, entry testLDAPEntry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !testLDAPMatch(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterEqualityMatch:
+		for _, value := range testLDAPValues(entry, filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(testLDAPValues(entry, filter.Data.String())) > 0
+	}
+	return false
+}
+
+func testLDAPValues(entry testLDAPEntry, name string) []string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func testLDAPMessage(msgID int64, op *ber.Packet) []byte {
+	message := ber.NewSequence("LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "Message ID"))
+	message.AppendChild(op)
+	return message.Bytes()
+}
+
+func testLDAPResult(tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
+
+func testLDAPSearchEntry(entry testLDAPEntry, requested []string) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+
+	attributes := ber.NewSequence("Attributes")
+	for _, name := range requested {
+		values := testLDAPValues(entry, name)
+		if len(values) == 0 {
+			continue
+		}
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	result.AppendChild(attributes)
+	return result
+}
+
+func testLDAPAuthenticator(url string) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		URL:               url,
+		BindDN:            testServiceDN,
+		BindPassword:      testServicePassword,
+		BaseDN:            "DC=example,DC=com",
+		UserObjectClass:   "person",
+		UsernameAttribute: "sAMAccountName",
+		GroupAttribute:    "memberOf",
+		RoleGroups: map[string]string{
+			testAdminsGroup: "admin",
+			testGuardsGroup: "Basic User",
+		},
+		AreaGroups: map[string]config.LDAPArea{
+			testNorthGroup: {GroupId: 1, AreaName: "North"},
+		},
+		Timeout: 2 * time.Second,
+	}
+}
+
+// useTestRoleRanks ranks the mapped roles without the roles table
+func useTestRoleRanks(t *testing.T) {
+	ranks := map[string]int{"Basic User": 2, "admin": 40}
+	previous := roleRank
+	roleRank = func(role string) int { return ranks[role] }
+	t.Cleanup(func() { roleRank = previous })
+}
+
+func testDirectory(t *testing.T) *testLDAPServer {
+	return newTestLDAPServer(t,
+		testLDAPEntry{
+			DN:       "CN=Alice,OU=Users,DC=example,DC=com",
+			Password: "alice-password",
+			Attributes: map[string][]string{
+				"objectClass":    {"top", "person"},
+				"sAMAccountName": {"alice"},
+				"memberOf":       {testGuardsGroup, strings.ToLower(testAdminsGroup), testNorthGroup},
+			},
+		},
+		testLDAPEntry{
+			DN:       "CN=Bob,OU=Users,DC=example,DC=com",
+			Password: "bob-password",
+			Attributes: map[string][]string{
+				"objectClass":    {"person"},
+				"sAMAccountName": {"bob"},
+				"memberOf":       {"CN=Accounting,OU=Groups,DC=example,DC=com", testNorthGroup},
+			},
+		},
+		testLDAPEntry{
+			DN:       "CN=Carol,OU=Users,DC=example,DC=com",
+			Password: "carol-password",
+			Attributes: map[string][]string{
+				"objectClass":    {"person"},
+				"sAMAccountName": {"carol"},
+				"memberOf":       {testGuardsGroup},
+				"vmsGroupId":     {"7"},
+				"vmsAreaName":    {"West"},
+			},
+		},
+	)
+}
+
+func TestLDAPLookup(t *testing.T) {
+	useTestRoleRanks(t)
+	authenticator := testLDAPAuthenticator(testDirectory(t).URL())
+
+	identity, err := authenticator.lookup("alice", "alice-password")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if identity.Role != "admin" || identity.GroupId != 1 || identity.AreaName != "North" {
+		t.Errorf("identity = %+v, want admin in North (1)", identity)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "wrong"},
+		{"empty password", "alice", ""},
+		{"unknown user", "mallory", "alice-password"},
+		{"wildcard username", "*", "alice-password"},
+		{"filter injection", "alice)(sAMAccountName=*", "alice-password"},
+		{"no VMS group", "bob", "bob-password"},
+		{"no area", "carol", "carol-password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := authenticator.lookup(tt.username, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("lookup error = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+func TestLDAPLookupAreaAttributes(t *testing.T) {
+	useTestRoleRanks(t)
+	authenticator := testLDAPAuthenticator(testDirectory(t).URL())
+	authenticator.GroupIdAttribute = "vmsGroupId"
+	authenticator.AreaNameAttribute = "vmsAreaName"
+
+	identity, err := authenticator.lookup("carol", "carol-password")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if identity.Role != "Basic User" || identity.GroupId != 7 || identity.AreaName != "West" {
+		t.Errorf("identity = %+v, want Basic User in West (7)", identity)
+	}
+
+	// Without the attribute the area groups are still used
+	identity, err = authenticator.lookup("alice", "alice-password")
+	if err != nil || identity.GroupId != 1 {
+		t.Fatalf("lookup = %+v, %v, want group 1", identity, err)
+	}
+}
+
+func TestLDAPBackendErrors(t *testing.T) {
+	server := testDirectory(t)
+
+	misconfigured := testLDAPAuthenticator(server.URL())
+	misconfigured.BindPassword = "wrong"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := testLDAPAuthenticator("ldap://" + listener.Addr().String())
+	listener.Close()
+
+	tests := []struct {
+		name          string
+		authenticator *LDAPAuthenticator
+	}{
+		{"service bind rejected", misconfigured},
+		{"server unreachable", down},
+		{"unsupported scheme", testLDAPAuthenticator("http://" + listener.Addr().String())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.authenticator.lookup("alice", "alice-password")
+			if err == nil || errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("lookup error = %v, want a backend error", err)
+			}
+		})
+	}
+}
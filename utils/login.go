@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"go-auth/models"
+)
+
+// ErrInvalidCredentials is returned for an unknown username or a wrong password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// CheckCredentials verifies a username and password against each configured
+// authenticator in turn, with login throttling applied.
+// It returns a *ThrottleError while the username or client IP is blocked.
+func CheckCredentials(r *http.Request, username, password string) (*models.User, error) {
+	ip := ClientIP(r)
+	if err := CheckLoginThrottle(username, ip); err != nil {
+		return nil, err
+	}
+
+	for _, authenticator := range Authenticators() {
+		user, err := authenticator.Authenticate(username, password)
+		if err == nil {
+			RecordLoginSuccess(username)
+			return user, nil
+		}
+		// An unreachable backend falls through to the next one, like a
+		// rejected login, but is logged so an outage does not go unnoticed
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("%s authentication failed for %q: %v", authenticator.Name(), username, err)
+		}
+	}
+
+	RecordLoginFailure(username, ip)
+	return nil, ErrInvalidCredentials
+}
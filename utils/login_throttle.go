@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ThrottleError is returned while a username or IP is blocked from logging in
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %d seconds", retryAfterSeconds(e.RetryAfter))
+}
+
+// CheckLoginThrottle returns a *ThrottleError if the username or IP is currently blocked
+func CheckLoginThrottle(username, ip string) error {
+	var longest time.Duration
+	for _, key := range throttleKeys(username, ip) {
+		var throttle models.LoginThrottle
+		if db.DB.Where("throttle_key = ?", key).First(&throttle).Error != nil {
+			continue
+		}
+		if throttle.BlockedUntil != nil {
+			if wait := time.Until(*throttle.BlockedUntil); wait > longest {
+				longest = wait
+			}
+		}
+	}
+
+	if longest > 0 {
+		return &ThrottleError{RetryAfter: longest}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed attempt against the username and IP
+func RecordLoginFailure(username, ip string) {
+	if username != "" {
+		recordThrottleFailure("user:"+username, config.LOGIN_USER_BACKOFF_AFTER, config.LOGIN_USER_LOCKOUT_AFTER)
+	}
+	if ip != "" {
+		recordThrottleFailure("ip:"+ip, config.LOGIN_IP_BACKOFF_AFTER, config.LOGIN_IP_LOCKOUT_AFTER)
+	}
+}
+
+// RecordLoginSuccess clears the failure counter for a username
+func RecordLoginSuccess(username string) {
+	db.DB.Unscoped().Where("throttle_key = ?", "user:"+username).Delete(&models.LoginThrottle{})
+}
+
+// UnlockLogin removes the throttle state for a username and, optionally, an IP
+func UnlockLogin(username, ip string) error {
+	keys := throttleKeys(username, ip)
+	return db.DB.Unscoped().Where("throttle_key IN ?", keys).Delete(&models.LoginThrottle{}).Error
+}
+
+// SendTooManyAttempts sends a 429 response with a Retry-After header
+func SendTooManyAttempts(w http.ResponseWriter, err *ThrottleError) {
+	seconds := retryAfterSeconds(err.RetryAfter)
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	SendJSON(w, map[string]interface{}{
+		"error":      "Too many failed login attempts",
+		"retryAfter": seconds,
+	}, http.StatusTooManyRequests)
+}
+
+// recordThrottleFailure increments a counter and works out how long the key is
+// blocked for. The increment is a single UPDATE inside a transaction, so
+// concurrent failures serialize on the row and none are lost.
+func recordThrottleFailure(key string, backoffAfter, lockoutAfter int) {
+	now := time.Now()
+	window := time.Minute * config.LOGIN_FAILURE_WINDOW_MINUTES
+	lockout := time.Minute * config.LOGIN_LOCKOUT_MINUTES
+
+	db.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the counter row exists before incrementing it
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{ThrottleKey: key, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		// Old failures are forgotten once the window has passed and no block is
+		// active. failures is assigned first so it sees the old last_failure_at.
+		err = tx.Exec(`UPDATE login_throttles
+			SET failures = CASE WHEN (blocked_until IS NULL OR blocked_until <= ?) AND last_failure_at < ? THEN 1 ELSE failures + 1 END,
+				last_failure_at = ?
+			WHERE throttle_key = ?`, now, now.Add(-window), now, key).Error
+		if err != nil {
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		if err := tx.Where("throttle_key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		var blockedUntil *time.Time
+		if throttle.Failures >= lockoutAfter {
+			until := now.Add(lockout)
+			blockedUntil = &until
+		} else if throttle.Failures >= backoffAfter {
+			exponent := float64(throttle.Failures - backoffAfter)
+			wait := time.Duration(float64(time.Second*config.LOGIN_BACKOFF_BASE_SECONDS) * math.Pow(2, exponent))
+			if wait > lockout {
+				wait = lockout
+			}
+			until := now.Add(wait)
+			blockedUntil = &until
+		}
+
+		return tx.Model(&throttle).Update("blocked_until", blockedUntil).Error
+	})
+}
+
+// throttleKeys returns the counter keys checked for a login attempt
+func throttleKeys(username, ip string) []string {
+	var keys []string
+	if username != "" {
+		keys = append(keys, "user:"+username)
+	}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
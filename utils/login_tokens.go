@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+
+	"gorm.io/gorm"
+)
+
+// Login-link failures, distinguished so the browser flow can redirect with a specific error
+var (
+	ErrLoginTokenInvalid   = errors.New("invalid token")
+	ErrLoginTokenRevoked   = errors.New("token revoked")
+	ErrLoginTokenExpired   = errors.New("token expired")
+	ErrLoginTokenSignature = errors.New("invalid token signature")
+	ErrLoginTokenUsedUp    = errors.New("token has reached its maximum number of uses")
+)
+
+// CreateLoginToken stores the hash of a signed login-link token for user
+func CreateLoginToken(user *models.User, rawToken string, record models.Token) (*models.Token, error) {
+	record.UserID = user.ID
+	record.Username = user.Username
+	record.GroupId = user.GroupId
+	record.AreaName = user.AreaName
+	record.Role = user.Role
+	record.Token = HashToken(rawToken)
+
+	if err := db.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ConsumeLoginToken validates a login-link token and counts one use against it.
+// The record is also returned with the error when the token exists but is unusable.
+func ConsumeLoginToken(rawToken string) (*models.Token, error) {
+	var record models.Token
+	if err := db.DB.Where("token = ?", HashToken(rawToken)).First(&record).Error; err != nil {
+		return nil, ErrLoginTokenInvalid
+	}
+
+	if record.RevokedAt != nil {
+		return &record, ErrLoginTokenRevoked
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return &record, ErrLoginTokenExpired
+	}
+	if valid, err := VerifyTokenJWT(rawToken); err != nil || !valid {
+		return &record, ErrLoginTokenSignature
+	}
+
+	// Count the use atomically so concurrent redemptions cannot exceed MaxUses
+	now := time.Now()
+	result := db.DB.Model(&models.Token{}).
+		Where("id = ? AND revoked_at IS NULL AND (max_uses = 0 OR use_count < max_uses)", record.ID).
+		Updates(map[string]interface{}{
+			"use_count":    gorm.Expr("use_count + 1"),
+			"is_used":      true,
+			"last_used_at": now,
+		})
+	if result.Error != nil {
+		return &record, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &record, ErrLoginTokenUsedUp
+	}
+
+	if record.UsedAt == nil {
+		db.DB.Model(&record).Update("used_at", now)
+	}
+	record.IsUsed = true
+	record.UseCount++
+	record.LastUsedAt = &now
+
+	return &record, nil
+}
+
+// LoginTokenUser returns the user a login link was issued for, without
+// checking or using up the link
+func LoginTokenUser(rawToken string) (*models.User, error) {
+	var record models.Token
+	if err := db.DB.Where("token = ?", HashToken(rawToken)).First(&record).Error; err != nil {
+		return nil, ErrLoginTokenInvalid
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, record.UserID).Error; err != nil {
+		return nil, ErrLoginTokenInvalid
+	}
+	return &user, nil
+}
+
+// GetUserLoginTokens lists the login links issued for a user, newest first
+func GetUserLoginTokens(userID uint) ([]models.Token, error) {
+	var tokens []models.Token
+	err := db.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeLoginToken revokes one login link and ends the sessions started from it
+func RevokeLoginToken(id uint) error {
+	if err := db.DB.Model(&models.Token{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return revokeLoginTokenSessions(db.DB.Model(&models.Token{}).Select("id").Where("id = ?", id))
+}
+
+// RevokeUserLoginTokens revokes every login link issued for a user and ends
+// the sessions started from them
+func RevokeUserLoginTokens(userID uint) error {
+	if err := db.DB.Model(&models.Token{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return revokeLoginTokenSessions(db.DB.Model(&models.Token{}).Select("id").Where("user_id = ?", userID))
+}
+
+// revokeLoginTokenSessions revokes the session families started from the given tokens
+func revokeLoginTokenSessions(tokenIDs *gorm.DB) error {
+	var sessionIDs []uint
+	if err := db.DB.Model(&models.Session{}).
+		Where("login_token_id IN (?) AND revoked_at IS NULL", tokenIDs).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range sessionIDs {
+		if err := RevokeSessionFamily(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashLegacyLoginTokens replaces plaintext login-link tokens with their hash.
+// It runs before AutoMigrate narrows the token column. The links keep working
+// through the legacy verifier until LEGACY_TOKEN_CUTOFF, so their expiry is
+// capped there to show when they really stop.
+func HashLegacyLoginTokens() error {
+	if !db.DB.Migrator().HasTable(&models.Token{}) {
+		return nil
+	}
+
+	cutoff, err := time.Parse(time.RFC3339, config.LEGACY_TOKEN_CUTOFF)
+	if err != nil || !config.ACCEPT_LEGACY_TOKENS {
+		cutoff = time.Now()
+	}
+
+	var rows []struct {
+		ID        uint
+		Token     string
+		ExpiresAt time.Time
+	}
+	// Hashes are hex; every legacy token is a JWT and contains dots
+	if err := db.DB.Model(&models.Token{}).Unscoped().
+		Select("id, token, expires_at").Where("token LIKE ?", "%.%").
+		Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		expiresAt := row.ExpiresAt
+		if expiresAt.After(cutoff) {
+			expiresAt = cutoff
+		}
+		if err := db.DB.Model(&models.Token{}).Unscoped().
+			Where("id = ?", row.ID).
+			Updates(map[string]interface{}{
+				"token":      HashToken(row.Token),
+				"expires_at": expiresAt,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
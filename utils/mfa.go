@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// StartTOTPEnrollment generates a new secret and recovery codes for user.
+// The enrollment stays inactive until ConfirmTOTPEnrollment succeeds.
+func StartTOTPEnrollment(user *models.User) (string, []string, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = db.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	}).Error
+	if err != nil {
+		return "", nil, err
+	}
+
+	return secret, codes, nil
+}
+
+// ConfirmTOTPEnrollment activates a pending enrollment once the user proves they hold the secret
+func ConfirmTOTPEnrollment(user *models.User, code string) error {
+	if user.TOTPSecret == "" {
+		return fmt.Errorf("no TOTP enrollment in progress")
+	}
+
+	step, ok := ValidateTOTP(user.TOTPSecret, normalizeMFACode(code), time.Now())
+	if !ok {
+		return fmt.Errorf("invalid code")
+	}
+
+	return db.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+	}).Error
+}
+
+// ResetTOTPEnrollment removes a user's TOTP secret and recovery codes
+func ResetTOTPEnrollment(userID uint) error {
+	err := db.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return db.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// VerifyMFACode checks a TOTP code or an unused recovery code for user
+func VerifyMFACode(user *models.User, code string) error {
+	code = normalizeMFACode(code)
+
+	if step, ok := ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		// Reject replays of a code from an already used time step
+		result := db.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return fmt.Errorf("invalid code")
+		}
+		return nil
+	}
+
+	result := db.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, HashToken(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return fmt.Errorf("invalid code")
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores a fresh hashed set
+func replaceRecoveryCodes(userID uint) ([]string, error) {
+	if err := db.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, config.RECOVERY_CODE_COUNT)
+	for i := range codes {
+		raw, err := GenerateRandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+
+		record := models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: HashToken(normalizeMFACode(codes[i])),
+		}
+		if err := db.DB.Create(&record).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// normalizeMFACode strips separators users commonly type into codes
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ReplaceAll(code, "-", "")
+}
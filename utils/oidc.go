@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-auth/config"
+)
+
+// OIDCProvider holds the discovered endpoints and cached signing keys of an OpenID Connect IdP
+type OIDCProvider struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+	ClientID              string
+	ClientSecret          string
+	RedirectURL           string
+	Scopes                string
+	HTTPClient            *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// Allowed clock difference between us and the IdP when checking exp/iat
+const oidcClockSkew = time.Minute
+
+var (
+	oidcProviderMu sync.Mutex
+	oidcProvider   *OIDCProvider
+)
+
+// GetOIDCProvider returns the IdP configured in config, running discovery on first use
+func GetOIDCProvider() (*OIDCProvider, error) {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	provider, err := DiscoverOIDCProvider(config.OIDC_ISSUER_URL, config.OIDC_CLIENT_ID,
+		config.OIDC_CLIENT_SECRET, config.OIDC_REDIRECT_URL, config.OIDC_SCOPES)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvider = provider
+	return oidcProvider, nil
+}
+
+// DiscoverOIDCProvider reads the issuer's /.well-known/openid-configuration document
+func DiscoverOIDCProvider(issuer, clientID, clientSecret, redirectURL, scopes string) (*OIDCProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery failed: status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: invalid document")
+	}
+
+	// The discovered issuer must match exactly (OpenID Connect Discovery 1.0, section 4.3)
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("oidc discovery failed: issuer mismatch %q", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery failed: missing endpoints")
+	}
+
+	return &OIDCProvider{
+		Issuer:                doc.Issuer,
+		AuthorizationEndpoint: doc.AuthorizationEndpoint,
+		TokenEndpoint:         doc.TokenEndpoint,
+		JWKSURI:               doc.JWKSURI,
+		ClientID:              clientID,
+		ClientSecret:          clientSecret,
+		RedirectURL:           redirectURL,
+		Scopes:                scopes,
+		HTTPClient:            client,
+	}, nil
+}
+
+// GeneratePKCEVerifier returns a random PKCE code verifier (RFC 7636)
+func GeneratePKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateOIDCState signs the state, nonce and PKCE verifier of a login in progress
+// so they can wait in a cookie until the IdP redirects back
+func CreateOIDCState(state, nonce, verifier string) (string, error) {
+	expiresAt := time.Now().Add(time.Minute * config.OIDC_STATE_EXPIRY_MINUTES)
+	return CreatePurposeJWT("oidc_state", map[string]interface{}{
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}, expiresAt)
+}
+
+// CheckOIDCState verifies the state cookie against the state the IdP returned
+// and gives back the nonce and PKCE verifier stored with it
+func CheckOIDCState(stateToken, state string) (nonce, verifier string, err error) {
+	stored, err := VerifyPurposeJWT(stateToken, "oidc_state")
+	if err != nil {
+		return "", "", err
+	}
+	if storedState, _ := stored["state"].(string); state == "" || storedState != state {
+		return "", "", fmt.Errorf("oidc state mismatch")
+	}
+
+	nonce, _ = stored["nonce"].(string)
+	verifier, _ = stored["verifier"].(string)
+	return nonce, verifier, nil
+}
+
+// AuthCodeURL builds the authorization request URL using the S256 PKCE method
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", p.Scopes)
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns the raw ID token
+func (p *OIDCProvider) Exchange(code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.ClientID)
+
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token exchange failed: invalid response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token exchange failed: no id_token in response")
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken validates an ID token's signature, issuer, audience, expiry and nonce
+func (p *OIDCProvider) VerifyIDToken(rawIDToken, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid id_token format")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("invalid id_token header")
+	}
+
+	key, err := p.signingKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token signature")
+	}
+	if err := VerifyJWSSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("invalid id_token signature: %v", err)
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token payload")
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		return nil, fmt.Errorf("invalid id_token payload")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, fmt.Errorf("id_token issuer mismatch")
+	}
+
+	audiences := claimStrings(claims["aud"])
+	if !containsString(audiences, p.ClientID) {
+		return nil, fmt.Errorf("id_token audience mismatch")
+	}
+	if len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, fmt.Errorf("id_token authorized party mismatch")
+		}
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-oidcClockSkew).Unix() > int64(exp) {
+		return nil, fmt.Errorf("id_token expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && int64(iat) > now.Add(oidcClockSkew).Unix() {
+		return nil, fmt.Errorf("id_token issued in the future")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// signingKey returns the IdP key with the given kid, refetching the JWKS when
+// an unknown kid shows up (the IdP may have rotated its keys)
+func (p *OIDCProvider) signingKey(kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	// Don't let tokens with made-up kids hammer the IdP
+	if !p.keysFetched.IsZero() && time.Since(p.keysFetched) < time.Minute {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchJWKS()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key; an empty kid matches only when the IdP publishes a single key
+func (p *OIDCProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// fetchJWKS downloads the IdP's signing keys
+func (p *OIDCProvider) fetchJWKS() (map[string]crypto.PublicKey, error) {
+	resp, err := p.HTTPClient.Get(p.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: invalid document")
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := ParseJWK(jwk)
+		if err != nil {
+			continue // skip key types we don't support
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// claimStrings normalises a claim that may be a string or an array of strings
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
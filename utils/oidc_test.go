@@ -0,0 +1,418 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClientID = "vms-test"
+
+// mockIdP is an in-process OpenID Connect provider: discovery, an authorize
+// endpoint that redirects straight back with a code, a token endpoint that
+// enforces PKCE, and a JWKS endpoint
+type mockIdP struct {
+	*httptest.Server
+	t *testing.T
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	kid   string
+	codes map[string]mockAuthRequest
+}
+
+// mockAuthRequest is what the IdP remembers about an authorization code
+type mockAuthRequest struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{t: t, codes: make(map[string]mockAuthRequest)}
+	idp.rotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// rotateKey replaces the IdP's signing key with a new one under a new kid
+func (idp *mockIdP) rotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.kid = randomID(idp.t)
+}
+
+func (idp *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" ||
+		query.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomID(idp.t)
+	idp.mu.Lock()
+	idp.codes[code] = mockAuthRequest{
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+	}
+	idp.mu.Unlock()
+
+	back := url.Values{"code": {code}, "state": {query.Get("state")}}
+	http.Redirect(w, r, query.Get("redirect_uri")+"?"+back.Encode(), http.StatusFound)
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	// Codes are single-use, whatever the outcome
+	idp.mu.Lock()
+	request, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != request.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != request.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"id_token": idp.idToken(map[string]interface{}{"nonce": request.nonce}),
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+		Kty: "RSA",
+		Kid: idp.kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+	}}})
+}
+
+// idToken signs a valid ID token with the current key; overrides replace or,
+// when nil, remove claims
+func (idp *mockIdP) idToken(overrides map[string]interface{}) string {
+	idp.mu.Lock()
+	key, kid := idp.key, idp.kid
+	idp.mu.Unlock()
+
+	claims := map[string]interface{}{
+		"iss":                idp.URL,
+		"sub":                "user-1",
+		"aud":                testClientID,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"preferred_username": "alice",
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return signRS256(idp.t, key, kid, claims)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func randomID(t *testing.T) string {
+	id, err := GenerateRandomToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// useTestKeyRing loads an in-memory HS256 key so purpose tokens can be signed
+// without a database
+func useTestKeyRing(t *testing.T) {
+	record, err := GenerateSigningKey("HS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseSigningKey(*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyRing.Lock()
+	keyRing.keys = map[string]*signingKey{record.Kid: key}
+	keyRing.active = key
+	keyRing.loadedAt = time.Now()
+	keyRing.Unlock()
+
+	t.Cleanup(func() {
+		keyRing.Lock()
+		keyRing.keys, keyRing.active, keyRing.loadedAt = nil, nil, time.Time{}
+		keyRing.Unlock()
+	})
+}
+
+func discoverMockIdP(t *testing.T, idp *mockIdP) *OIDCProvider {
+	provider, err := DiscoverOIDCProvider(idp.URL, testClientID, "", "http://vms.test/oidc/callback", "openid")
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	return provider
+}
+
+// authorize follows the authorization URL and returns the code and state the IdP sent back
+func authorize(t *testing.T, authURL string) (code, state string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	useTestKeyRing(t)
+	idp := newMockIdP(t)
+	provider := discoverMockIdP(t, idp)
+
+	state, nonce := randomID(t), randomID(t)
+	verifier, err := GeneratePKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateToken, err := CreateOIDCState(state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, returnedState := authorize(t, provider.AuthCodeURL(state, nonce, verifier))
+
+	storedNonce, storedVerifier, err := CheckOIDCState(stateToken, returnedState)
+	if err != nil {
+		t.Fatalf("state check: %v", err)
+	}
+	rawIDToken, err := provider.Exchange(code, storedVerifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(rawIDToken, storedNonce)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims["preferred_username"] != "alice" {
+		t.Errorf("preferred_username = %v, want alice", claims["preferred_username"])
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	if _, err := DiscoverOIDCProvider(idp.URL+"/", testClientID, "", "", "openid"); err == nil {
+		t.Fatal("discovery accepted an issuer that does not match the configured one")
+	}
+}
+
+func TestOIDCPKCE(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := discoverMockIdP(t, idp)
+
+	verifier, err := GeneratePKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL := provider.AuthCodeURL("state", "nonce", verifier)
+	parsed, _ := url.Parse(authURL)
+	if parsed.Query().Get("code_verifier") != "" || strings.Contains(authURL, verifier) {
+		t.Fatal("authorization URL leaks the PKCE verifier")
+	}
+
+	code, _ := authorize(t, authURL)
+	otherVerifier, _ := GeneratePKCEVerifier()
+	if _, err := provider.Exchange(code, otherVerifier); err == nil {
+		t.Fatal("exchange succeeded with the wrong code verifier")
+	}
+
+	code, _ = authorize(t, authURL)
+	if _, err := provider.Exchange(code, verifier); err != nil {
+		t.Fatalf("exchange with the right verifier: %v", err)
+	}
+	if _, err := provider.Exchange(code, verifier); err == nil {
+		t.Fatal("authorization code was accepted twice")
+	}
+}
+
+func TestOIDCState(t *testing.T) {
+	useTestKeyRing(t)
+
+	stateToken, err := CreateOIDCState("expected", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPurpose, err := CreatePurposeJWT("mfa", map[string]interface{}{"state": "expected"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := CreatePurposeJWT("oidc_state", map[string]interface{}{"state": "expected"}, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(stateToken, ".")
+	forgedPayload, _ := json.Marshal(map[string]interface{}{
+		"purpose": "oidc_state",
+		"state":   "forged",
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	})
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(forgedPayload) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		state string
+	}{
+		{"wrong state", stateToken, "other"},
+		{"missing state", stateToken, ""},
+		{"other purpose", otherPurpose, "expected"},
+		{"expired", expired, "expected"},
+		{"tampered payload", forged, "forged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := CheckOIDCState(tt.token, tt.state); err == nil {
+				t.Fatal("state check passed")
+			}
+		})
+	}
+
+	nonce, verifier, err := CheckOIDCState(stateToken, "expected")
+	if err != nil || nonce != "nonce" || verifier != "verifier" {
+		t.Fatalf("CheckOIDCState = %q, %q, %v", nonce, verifier, err)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := discoverMockIdP(t, idp)
+
+	strangerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := idp.idToken(map[string]interface{}{"nonce": "n"})
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"signed by an unpublished key", signRS256(t, strangerKey, idp.kid, map[string]interface{}{
+			"iss": idp.URL, "aud": testClientID, "nonce": "n", "exp": time.Now().Add(time.Minute).Unix(),
+		})},
+		{"unknown kid", signRS256(t, strangerKey, "unknown", map[string]interface{}{
+			"iss": idp.URL, "aud": testClientID, "nonce": "n", "exp": time.Now().Add(time.Minute).Unix(),
+		})},
+		{"unsigned", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."},
+		{"wrong issuer", idp.idToken(map[string]interface{}{"nonce": "n", "iss": "https://evil.test"})},
+		{"wrong audience", idp.idToken(map[string]interface{}{"nonce": "n", "aud": "another-client"})},
+		{"extra audience without azp", idp.idToken(map[string]interface{}{"nonce": "n", "aud": []string{testClientID, "another-client"}})},
+		{"expired", idp.idToken(map[string]interface{}{"nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()})},
+		{"missing exp", idp.idToken(map[string]interface{}{"nonce": "n", "exp": nil})},
+		{"issued in the future", idp.idToken(map[string]interface{}{"nonce": "n", "iat": time.Now().Add(time.Hour).Unix()})},
+		{"wrong nonce", idp.idToken(map[string]interface{}{"nonce": "other"})},
+		{"missing nonce", idp.idToken(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.VerifyIDToken(tt.token, "n"); err == nil {
+				t.Fatal("id_token accepted")
+			}
+		})
+	}
+
+	if _, err := provider.VerifyIDToken(valid, "n"); err != nil {
+		t.Fatalf("valid id_token rejected: %v", err)
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := discoverMockIdP(t, idp)
+
+	if _, err := provider.VerifyIDToken(idp.idToken(map[string]interface{}{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("verify before rotation: %v", err)
+	}
+
+	idp.rotateKey()
+	rotated := idp.idToken(map[string]interface{}{"nonce": "n"})
+
+	// A new kid right after a fetch does not trigger another one
+	if _, err := provider.VerifyIDToken(rotated, "n"); err == nil {
+		t.Fatal("JWKS was refetched within the minimum interval")
+	}
+
+	provider.mu.Lock()
+	provider.keysFetched = time.Now().Add(-2 * time.Minute)
+	provider.mu.Unlock()
+	if _, err := provider.VerifyIDToken(rotated, "n"); err != nil {
+		t.Fatalf("verify after rotation: %v", err)
+	}
+}
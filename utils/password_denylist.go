@@ -0,0 +1,50 @@
+package utils
+
+// commonPasswords is a denylist of passwords that appear at the top of
+// breach corpora. Entries are compared case-insensitively, after the length
+// check, so none is shorter than PASSWORD_MIN_LENGTH.
+var commonPasswords = map[string]bool{
+	"123456789012":     true,
+	"1234567890123":    true,
+	"abc123456789":     true,
+	"admin1234567":     true,
+	"administrator":    true,
+	"administrator1":   true,
+	"changeme12345":    true,
+	"iloveyou1234":     true,
+	"letmein12345":     true,
+	"password1234":     true,
+	"password12345":    true,
+	"password123456":   true,
+	"password123!":     true,
+	"password1234!":    true,
+	"p@ssw0rd1234":     true,
+	"p@ssword1234":     true,
+	"passw0rd1234":     true,
+	"qwerty123456":     true,
+	"qwertyuiop12":     true,
+	"qwerty123456!":    true,
+	"welcome12345":     true,
+	"welcome123456!":   true,
+	"welcome1234!":     true,
+	"security1234":     true,
+	"security123!":     true,
+	"camera123456":     true,
+	"summer2024!!":     true,
+	"winter2024!!":     true,
+	"spring2025!!":     true,
+	"autumn2025!!":     true,
+	"trustno1trustno1": true,
+	"monkey123456":     true,
+	"dragon123456":     true,
+	"football1234":     true,
+	"baseball1234":     true,
+	"sunshine1234":     true,
+	"princess1234":     true,
+	"superman1234":     true,
+	"1q2w3e4r5t6y":     true,
+	"1qaz2wsx3edc":     true,
+	"zaq12wsxcde3":     true,
+	"aa123456789a":     true,
+	"passwordpassword": true,
+}
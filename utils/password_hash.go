@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2idPrefix starts every argon2id hash, stored in the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+const argon2idPrefix = "$argon2id$"
+
+var errUnknownPasswordHash = errors.New("unknown password hash format")
+
+// argon2Params are the tunable argon2id parameters encoded in a hash
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// currentArgon2Params returns the configured parameters for new hashes
+func currentArgon2Params() argon2Params {
+	return argon2Params{
+		memory:      config.ARGON2_MEMORY_KB,
+		iterations:  config.ARGON2_ITERATIONS,
+		parallelism: config.ARGON2_PARALLELISM,
+	}
+}
+
+// HashPassword hashes a password with the configured algorithm
+func HashPassword(password string) (string, error) {
+	if config.PASSWORD_HASH_ALG == "bcrypt" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), config.BCRYPT_COST)
+		return string(hash), err
+	}
+
+	salt := make([]byte, config.ARGON2_SALT_LENGTH)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	params := currentArgon2Params()
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, config.ARGON2_KEY_LENGTH)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a stored argon2id or bcrypt hash
+func VerifyPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(candidate, key) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// PasswordNeedsRehash reports whether a stored hash uses a different algorithm
+// or weaker parameters than new hashes would
+func PasswordNeedsRehash(hash string) bool {
+	if config.PASSWORD_HASH_ALG == "bcrypt" {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < config.BCRYPT_COST
+	}
+
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return true
+	}
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return params != currentArgon2Params() ||
+		len(salt) != config.ARGON2_SALT_LENGTH ||
+		len(key) != config.ARGON2_KEY_LENGTH
+}
+
+// decodeArgon2idHash splits a PHC-format argon2id hash into its parameters, salt and key
+func decodeArgon2idHash(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+
+	return params, salt, key, nil
+}
+
+// rehashPassword replaces a user's stored hash after a successful login. Only
+// the hash changes: the password's age and history are left alone.
+func rehashPassword(user *models.User, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	err = db.DB.Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Update("password", hash).Error
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// CountLegacyPasswordHashes returns how many local users still have a
+// password hash that is not argon2id
+func CountLegacyPasswordHashes() (int64, error) {
+	var count int64
+	err := db.DB.Model(&models.User{}).
+		Where("password <> '' AND password NOT LIKE ?", argon2idPrefix+"%").
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// ValidatePasswordPolicy checks a new password against the configured policy
+func ValidatePasswordPolicy(username, password string) error {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSymbol = true
+		}
+	}
+
+	var problems []string
+	if len([]rune(password)) < config.PASSWORD_MIN_LENGTH {
+		problems = append(problems, fmt.Sprintf("be at least %d characters", config.PASSWORD_MIN_LENGTH))
+	}
+	if config.PASSWORD_REQUIRE_UPPER && !hasUpper {
+		problems = append(problems, "contain an uppercase letter")
+	}
+	if config.PASSWORD_REQUIRE_LOWER && !hasLower {
+		problems = append(problems, "contain a lowercase letter")
+	}
+	if config.PASSWORD_REQUIRE_DIGIT && !hasDigit {
+		problems = append(problems, "contain a digit")
+	}
+	if config.PASSWORD_REQUIRE_SYMBOL && !hasSymbol {
+		problems = append(problems, "contain a symbol")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("password must %s", strings.Join(problems, ", "))
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("password is too common")
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return fmt.Errorf("password must not contain the username")
+	}
+
+	return nil
+}
+
+// CheckPasswordHistory rejects a password matching the current one or one of the last PASSWORD_HISTORY_COUNT
+func CheckPasswordHistory(user *models.User, password string) error {
+	if user.Password != "" && VerifyPassword(user.Password, password) {
+		return fmt.Errorf("password was used recently")
+	}
+
+	var history []models.PasswordHistory
+	db.DB.Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(config.PASSWORD_HISTORY_COUNT).
+		Find(&history)
+
+	for _, entry := range history {
+		if VerifyPassword(entry.PasswordHash, password) {
+			return fmt.Errorf("password was used recently")
+		}
+	}
+
+	return nil
+}
+
+// PasswordExpiry returns when a password set now expires, or nil if rotation is disabled
+func PasswordExpiry(changedAt time.Time) *time.Time {
+	if config.PASSWORD_MAX_AGE_DAYS <= 0 {
+		return nil
+	}
+	expiresAt := changedAt.Add(time.Hour * 24 * config.PASSWORD_MAX_AGE_DAYS)
+	return &expiresAt
+}
+
+// SetUserPassword hashes and stores a new password, recording it in the password history
+func SetUserPassword(user *models.User, password string, mustChange bool) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = db.DB.Model(user).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"password_changed_at":  now,
+		"password_expires_at":  PasswordExpiry(now),
+		"must_change_password": mustChange,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return RecordPasswordHistory(user.ID, hashedPassword)
+}
+
+// RecordPasswordHistory stores a password hash and prunes entries beyond the history limit
+func RecordPasswordHistory(userID uint, passwordHash string) error {
+	entry := models.PasswordHistory{
+		UserID:       userID,
+		PasswordHash: passwordHash,
+	}
+	if err := db.DB.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	var history []models.PasswordHistory
+	db.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&history)
+	for i := config.PASSWORD_HISTORY_COUNT; i < len(history); i++ {
+		db.DB.Unscoped().Delete(&history[i])
+	}
+
+	return nil
+}
+
+// PasswordChangeRequired reports whether a user must set a new password before getting a session
+func PasswordChangeRequired(user *models.User) bool {
+	if user.MustChangePassword {
+		return true
+	}
+	return user.PasswordExpiresAt != nil && time.Now().After(*user.PasswordExpiresAt)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// CreatePasswordResetToken issues a reset token for a user, replacing any unused ones.
+// Only the hash is stored; the raw token is returned to be handed to the user.
+func CreatePasswordResetToken(userID uint, createdBy string) (string, time.Time, error) {
+	// Earlier links stop working once a new one is issued
+	db.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("expires_at", time.Now())
+
+	rawToken, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: HashToken(rawToken),
+		ExpiresAt: time.Now().Add(time.Hour * config.PASSWORD_RESET_EXPIRY_HOURS),
+		CreatedBy: createdBy,
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	return rawToken, record.ExpiresAt, nil
+}
+
+// GetValidPasswordResetToken retrieves an unused, unexpired reset token by its raw value
+func GetValidPasswordResetToken(rawToken string) (*models.PasswordResetToken, error) {
+	var record models.PasswordResetToken
+	err := db.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", HashToken(rawToken), time.Now()).
+		First(&record).Error
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired reset token")
+	}
+	return &record, nil
+}
+
+// ConsumePasswordResetToken marks a reset token used, failing if it was already redeemed
+func ConsumePasswordResetToken(id uint) error {
+	result := db.DB.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+	return nil
+}
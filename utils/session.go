@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// Login methods recorded on sessions
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodLoginLink = "login_link"
+	LoginMethodSSO       = "sso"
+
+	LoginMethodImpersonation = "impersonation"
+)
+
+// IssueSession starts a new session for user and sets its access and refresh cookies.
+// It returns the expiry of the access JWT.
+func IssueSession(w http.ResponseWriter, r *http.Request, user models.User, method string) (time.Time, error) {
+	return startSession(w, r, user, &models.Session{LoginMethod: method})
+}
+
+// IssueLoginTokenSession starts a session from a login link; the session
+// inherits the link's read-only and view group restrictions
+func IssueLoginTokenSession(w http.ResponseWriter, r *http.Request, user models.User, token *models.Token) (time.Time, error) {
+	tokenID := token.ID
+	return startSession(w, r, user, &models.Session{
+		LoginMethod:  LoginMethodLoginLink,
+		LoginTokenID: &tokenID,
+		ReadOnly:     token.ReadOnly,
+		ViewGroupIDs: token.ViewGroupIDs,
+	})
+}
+
+// startSession stores session (pre-filled with any restrictions) for user and sets its cookies
+func startSession(w http.ResponseWriter, r *http.Request, user models.User, session *models.Session) (time.Time, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	session.JTI = jti
+	session.UserID = user.ID
+	session.Username = user.Username
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = now.Add(time.Hour * 24 * config.REFRESH_TOKEN_EXPIRY_DAYS)
+	}
+	session.IPAddress = ClientIP(r)
+	session.UserAgent = r.UserAgent()
+	if len(session.UserAgent) > 512 {
+		session.UserAgent = session.UserAgent[:512]
+	}
+	session.LastSeenAt = &now
+	if err := CreateSessionInDB(session); err != nil {
+		return time.Time{}, err
+	}
+
+	return issueSessionTokens(w, user, session)
+}
+
+// RefreshSession redeems a refresh token for a new access JWT and a new refresh token.
+// Presenting a refresh token that was already rotated revokes the whole family.
+// Refreshes of an impersonation session are audited.
+func RefreshSession(w http.ResponseWriter, r *http.Request, rawToken string) (time.Time, error) {
+	record, err := GetRefreshTokenByHash(HashToken(rawToken))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid refresh token")
+	}
+
+	if record.UsedAt != nil || record.RevokedAt != nil {
+		RevokeSessionFamily(record.SessionID)
+		return time.Time{}, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return time.Time{}, fmt.Errorf("refresh token expired")
+	}
+
+	session, err := GetActiveSessionByID(record.SessionID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("session revoked")
+	}
+
+	// Claim the token; losing the race means someone else already used it
+	claimed, err := MarkRefreshTokenUsed(record.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !claimed {
+		RevokeSessionFamily(record.SessionID)
+		return time.Time{}, ErrRefreshTokenReused
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, session.UserID).Error; err != nil {
+		return time.Time{}, fmt.Errorf("user not found")
+	}
+
+	// Give the session a new jti so the previous access JWT stops working
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := RotateSessionJTI(session.ID, jti); err != nil {
+		return time.Time{}, err
+	}
+	session.JTI = jti
+	TouchSession(session)
+	recordImpersonation(r, session, "refresh", "")
+
+	return issueSessionTokens(w, user, session)
+}
+
+// ResumeSession issues fresh cookies for an existing active session, e.g. an
+// admin's own session once impersonation ends
+func ResumeSession(w http.ResponseWriter, sessionID uint) (time.Time, error) {
+	session, err := GetActiveSessionByID(sessionID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("session revoked")
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, session.UserID).Error; err != nil {
+		return time.Time{}, fmt.Errorf("user not found")
+	}
+
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := RotateSessionJTI(session.ID, jti); err != nil {
+		return time.Time{}, err
+	}
+	session.JTI = jti
+	TouchSession(session)
+
+	return issueSessionTokens(w, user, session)
+}
+
+// issueSessionTokens mints an access JWT for the session's current jti and a
+// new refresh token in the session's family, and sets their cookies along
+// with the session's CSRF token
+func issueSessionTokens(w http.ResponseWriter, user models.User, session *models.Session) (time.Time, error) {
+	accessExpiresAt := time.Now().Add(time.Minute * config.ACCESS_TOKEN_EXPIRY_MINUTES)
+	if accessExpiresAt.After(session.ExpiresAt) {
+		accessExpiresAt = session.ExpiresAt
+	}
+
+	accessToken, err := CreateSessionJWT(user, session.JTI, accessExpiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	refreshToken, err := GenerateRandomToken(32)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	record := models.RefreshToken{
+		SessionID: session.ID,
+		UserID:    user.ID,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := CreateRefreshTokenInDB(&record); err != nil {
+		return time.Time{}, err
+	}
+
+	csrfToken, err := EnsureCSRFToken(session)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	SetSessionCookie(w, accessToken)
+	SetRefreshCookie(w, refreshToken, session.ExpiresAt)
+	SetCSRFCookie(w, csrfToken, session.ExpiresAt)
+
+	return accessExpiresAt, nil
+}
+
+// RefreshTokenSession returns the session a raw refresh token belongs to
+func RefreshTokenSession(rawToken string) (*models.Session, error) {
+	record, err := GetRefreshTokenByHash(HashToken(rawToken))
+	if err != nil {
+		return nil, err
+	}
+	var session models.Session
+	if err := db.DB.First(&session, record.SessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// EndSession revokes a session on logout. Logging out of an impersonation
+// session also revokes the admin session it was started from, so nobody is
+// left signed in as the admin.
+func EndSession(r *http.Request, session *models.Session) error {
+	if err := RevokeSessionFamily(session.ID); err != nil {
+		return err
+	}
+	if session.ImpersonatorID == nil {
+		return nil
+	}
+
+	recordImpersonation(r, session, "logout", "")
+	if session.ParentSessionID == nil {
+		return nil
+	}
+	return RevokeSessionFamily(*session.ParentSessionID)
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// CreateSessionInDB stores a new session record
+func CreateSessionInDB(session *models.Session) error {
+	return db.DB.Create(session).Error
+}
+
+// GetActiveSession retrieves a session by jti that is neither revoked nor expired
+func GetActiveSession(jti string) (*models.Session, error) {
+	var session models.Session
+	err := db.DB.Where("jti = ? AND revoked_at IS NULL AND expires_at > ?", jti, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveSessionByID retrieves a session by ID that is neither revoked nor expired
+func GetActiveSessionByID(id uint) (*models.Session, error) {
+	var session models.Session
+	err := db.DB.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSessionJTI replaces the jti of a session, invalidating its previous access JWT
+func RotateSessionJTI(sessionID uint, jti string) error {
+	return db.DB.Model(&models.Session{}).Where("id = ?", sessionID).Update("jti", jti).Error
+}
+
+// RevokeSession revokes the session with the given jti and its refresh token family
+func RevokeSession(jti string) error {
+	var session models.Session
+	if err := db.DB.Where("jti = ?", jti).First(&session).Error; err != nil {
+		return err
+	}
+	return RevokeSessionFamily(session.ID)
+}
+
+// RevokeSessionFamily revokes a session and every refresh token issued for it
+func RevokeSessionFamily(sessionID uint) error {
+	now := time.Now()
+	if err := db.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return db.DB.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error
+}
+
+// RevokeUserSessions revokes every active session and refresh token belonging to a user
+func RevokeUserSessions(userID uint) error {
+	now := time.Now()
+	if err := db.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return db.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// CreateRefreshTokenInDB stores a new refresh token record
+func CreateRefreshTokenInDB(refreshToken *models.RefreshToken) error {
+	return db.DB.Create(refreshToken).Error
+}
+
+// GetRefreshTokenByHash retrieves a refresh token record by its hash
+func GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	if err := db.DB.Where("token_hash = ?", hash).First(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+// MarkRefreshTokenUsed marks a refresh token as used. It reports false if the
+// token had already been used or revoked by the time of the update.
+func MarkRefreshTokenUsed(id uint) (bool, error) {
+	result := db.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetActiveUserSessions lists a user's sessions that are neither revoked nor expired, most recent first
+func GetActiveUserSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := db.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// TouchSession records that a session was just used, at most once per
+// SESSION_LAST_SEEN_UPDATE_SECONDS to avoid a write on every request
+func TouchSession(session *models.Session) {
+	now := time.Now()
+	interval := time.Duration(config.SESSION_LAST_SEEN_UPDATE_SECONDS) * time.Second
+	if session.LastSeenAt != nil && now.Sub(*session.LastSeenAt) < interval {
+		return
+	}
+	db.DB.Model(&models.Session{}).Where("id = ?", session.ID).Update("last_seen_at", now)
+	session.LastSeenAt = &now
+}
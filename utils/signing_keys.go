@@ -0,0 +1,396 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"go-auth/config"
+	"go-auth/db"
+	"go-auth/models"
+)
+
+// keyRingMinReload limits forced reloads triggered by tokens with an unknown kid
+const keyRingMinReload = 5 * time.Second
+
+// signingKey is a parsed key ring entry
+type signingKey struct {
+	record  models.SigningKey
+	secret  []byte        // HS256
+	private crypto.Signer // RS256, EdDSA
+}
+
+// keyRing caches the verifying keys so each request does not hit the database
+var keyRing struct {
+	sync.Mutex
+	keys     map[string]*signingKey
+	active   *signingKey
+	loadedAt time.Time
+}
+
+// loadKeyRing refreshes the cached key ring when it is stale (or force is set),
+// creating the first signing key if the ring is empty
+func loadKeyRing(force bool) error {
+	keyRing.Lock()
+	defer keyRing.Unlock()
+
+	age := time.Since(keyRing.loadedAt)
+	if keyRing.keys != nil && age < time.Duration(config.SIGNING_KEY_CACHE_SECONDS)*time.Second {
+		if !force || age < keyRingMinReload {
+			return nil
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -config.SIGNING_KEY_RETENTION_DAYS)
+	var records []models.SigningKey
+	if err := db.DB.Where("retired_at IS NULL AND (rotated_at IS NULL OR rotated_at > ?)", cutoff).
+		Order("created_at DESC").Find(&records).Error; err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(records))
+	var active *signingKey
+	for _, record := range records {
+		key, err := parseSigningKey(record)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %v", record.Kid, err)
+		}
+		keys[record.Kid] = key
+		if record.Active && active == nil {
+			active = key
+		}
+	}
+
+	if active == nil {
+		record, err := GenerateSigningKey(config.JWT_SIGNING_ALG)
+		if err != nil {
+			return err
+		}
+		record.Active = true
+		if err := db.DB.Create(record).Error; err != nil {
+			return err
+		}
+		active, err = parseSigningKey(*record)
+		if err != nil {
+			return err
+		}
+		keys[record.Kid] = active
+	}
+
+	keyRing.keys = keys
+	keyRing.active = active
+	keyRing.loadedAt = time.Now()
+	return nil
+}
+
+// activeSigningKey returns the key that signs new tokens
+func activeSigningKey() (*signingKey, error) {
+	if err := loadKeyRing(false); err != nil {
+		return nil, err
+	}
+	keyRing.Lock()
+	defer keyRing.Unlock()
+	return keyRing.active, nil
+}
+
+// verificationKey returns the key with the given kid, reloading once in case
+// another instance rotated recently
+func verificationKey(kid string) (*signingKey, error) {
+	for _, force := range []bool{false, true} {
+		if err := loadKeyRing(force); err != nil {
+			return nil, err
+		}
+		keyRing.Lock()
+		key := keyRing.keys[kid]
+		keyRing.Unlock()
+		if key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key")
+}
+
+// GenerateSigningKey creates (but does not store) a new key for alg
+func GenerateSigningKey(alg string) (*models.SigningKey, error) {
+	kid, err := GenerateRandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	var material []byte
+	switch alg {
+	case "HS256":
+		material = make([]byte, 32)
+		if _, err := rand.Read(material); err != nil {
+			return nil, err
+		}
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		if material, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return nil, err
+		}
+	case "EdDSA":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if material, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	return &models.SigningKey{
+		Kid:      kid,
+		Alg:      alg,
+		Material: base64.StdEncoding.EncodeToString(material),
+	}, nil
+}
+
+// parseSigningKey decodes the stored key material of a record
+func parseSigningKey(record models.SigningKey) (*signingKey, error) {
+	material, err := base64.StdEncoding.DecodeString(record.Material)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key material")
+	}
+
+	key := &signingKey{record: record}
+	switch record.Alg {
+	case "HS256":
+		key.secret = material
+		return key, nil
+	case "RS256", "EdDSA":
+		parsed, err := x509.ParsePKCS8PrivateKey(material)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("invalid private key")
+		}
+		_, isRSA := signer.(*rsa.PrivateKey)
+		if isRSA != (record.Alg == "RS256") {
+			return nil, fmt.Errorf("private key does not match %s", record.Alg)
+		}
+		key.private = signer
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", record.Alg)
+}
+
+// sign produces the JWS signature of message
+func (k *signingKey) sign(message string) ([]byte, error) {
+	switch k.record.Alg {
+	case "HS256":
+		h := hmac.New(sha256.New, k.secret)
+		h.Write([]byte(message))
+		return h.Sum(nil), nil
+	case "RS256":
+		digest := sha256.Sum256([]byte(message))
+		return k.private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case "EdDSA":
+		return k.private.Sign(rand.Reader, []byte(message), crypto.Hash(0))
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", k.record.Alg)
+}
+
+// verify checks a JWS signature of message
+func (k *signingKey) verify(message string, signature []byte) error {
+	if k.record.Alg == "HS256" {
+		expected, _ := k.sign(message)
+		if !hmac.Equal(signature, expected) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return VerifyJWSSignature(k.record.Alg, k.private.Public(), message, signature)
+}
+
+// signJWT serializes payload and signs it with the active key, naming it in the kid header
+func signJWT(payload interface{}) (string, error) {
+	key, err := activeSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	header := JWTHeader{
+		Alg: key.record.Alg,
+		Typ: "JWT",
+		Kid: key.record.Kid,
+	}
+
+	headerJSON, _ := json.Marshal(header)
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	message := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(payloadJSON)
+	signature, err := key.sign(message)
+	if err != nil {
+		return "", err
+	}
+
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifyJWT checks a token's signature against the key named by its kid and
+// returns the decoded payload. Tokens without a kid predate the key ring: they
+// are checked against legacySecret only when one is given, ACCEPT_LEGACY_TOKENS
+// is set and LEGACY_TOKEN_CUTOFF has not passed.
+func verifyJWT(token, legacySecret string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token format")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token header")
+	}
+	var header JWTHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("invalid token header")
+	}
+
+	message := parts[0] + "." + parts[1]
+
+	if header.Kid == "" {
+		if legacySecret == "" || !legacyTokensAccepted() || header.Alg != "HS256" {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		expected := CreateHMACSignature(message, legacySecret)
+		if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	} else {
+		key, err := verificationKey(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		// The key decides the algorithm, never the token
+		if header.Alg != key.record.Alg {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		if err := key.verify(message, signature); err != nil {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	}
+
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
+
+// legacyTokensAccepted reports whether kid-less tokens are still accepted
+func legacyTokensAccepted() bool {
+	if !config.ACCEPT_LEGACY_TOKENS {
+		return false
+	}
+	cutoff, err := time.Parse(time.RFC3339, config.LEGACY_TOKEN_CUTOFF)
+	return err == nil && time.Now().Before(cutoff)
+}
+
+// RotateSigningKey makes a new key of alg the signing key. The previous key
+// keeps verifying tokens for SIGNING_KEY_RETENTION_DAYS.
+func RotateSigningKey(alg string) (*models.SigningKey, error) {
+	record, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	record.Active = true
+
+	tx := db.DB.Begin()
+	if err := tx.Model(&models.SigningKey{}).Where("active = ?", true).
+		Updates(map[string]interface{}{"active": false, "rotated_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Create(record).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return record, loadKeyRing(true)
+}
+
+// RetireSigningKey stops a rotated-out key from verifying tokens
+func RetireSigningKey(kid string) error {
+	var record models.SigningKey
+	if err := db.DB.Where("kid = ?", kid).First(&record).Error; err != nil {
+		return fmt.Errorf("signing key not found")
+	}
+	if record.Active {
+		return fmt.Errorf("the active signing key cannot be retired; rotate first")
+	}
+	if err := db.DB.Model(&record).Update("retired_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return loadKeyRing(true)
+}
+
+// ListSigningKeys returns every key in the ring, newest first
+func ListSigningKeys() ([]models.SigningKey, error) {
+	var records []models.SigningKey
+	err := db.DB.Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+// PublicJWKS returns the public halves of the asymmetric keys that currently
+// verify tokens. HMAC keys are never published.
+func PublicJWKS() (JWKS, error) {
+	jwks := JWKS{Keys: []JWK{}}
+	if err := loadKeyRing(false); err != nil {
+		return jwks, err
+	}
+
+	keyRing.Lock()
+	defer keyRing.Unlock()
+	for kid, key := range keyRing.keys {
+		if key.private == nil {
+			continue
+		}
+		switch pub := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.record.Alg,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.record.Alg,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return jwks, nil
+}
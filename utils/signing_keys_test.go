@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-auth/config"
+)
+
+// legacyJWT signs claims the way tokens were signed before the key ring: HS256
+// with a shared secret and no kid
+func legacyJWT(t *testing.T, secret string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return message + "." + CreateHMACSignature(message, secret)
+}
+
+func TestLegacyLoginLinks(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub": "1",
+		"iat": time.Now().Add(-24 * time.Hour).Unix(),
+		"exp": time.Now().Add(24 * time.Hour).Unix(),
+	}
+
+	if valid, err := VerifyTokenJWT(legacyJWT(t, config.JWT_SECRET, claims)); legacyTokensAccepted() && (!valid || err != nil) {
+		t.Fatalf("legacy login link rejected before the cutoff: %v", err)
+	}
+	if valid, _ := VerifyTokenJWT(legacyJWT(t, "another secret", claims)); valid {
+		t.Fatal("kid-less token signed with another secret was accepted")
+	}
+
+	// Nothing but login links may use the legacy secret
+	if _, err := verifyJWT(legacyJWT(t, config.JWT_SECRET, claims), ""); err == nil {
+		t.Fatal("kid-less token accepted without a legacy secret")
+	}
+	if _, err := VerifyPurposeJWT(legacyJWT(t, config.JWT_SECRET, map[string]interface{}{
+		"purpose": "password_change",
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}), "password_change"); err == nil {
+		t.Fatal("kid-less purpose token was accepted")
+	}
+}
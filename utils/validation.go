@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+
+	"go-auth/policy"
+)
 
 // ValidateLoginRequest validates login credentials
 func ValidateLoginRequest(username, password string) error {
@@ -13,19 +17,16 @@ func ValidateLoginRequest(username, password string) error {
 	return nil
 }
 
-// validate roles
+// ValidateRole checks that role names a defined role
 func ValidateRole(role string) error {
-	allowedRole := []string{"Basic User", "Area Admin", "admin"}
-	for _, r := range allowedRole {
-		if role == r {
-			return nil
-		}
+	if !policy.RoleExists(role) {
+		return fmt.Errorf("invalid role: %s", role)
 	}
-	return fmt.Errorf("invalid role: must be one of 'Basic User', 'Area Admin', or 'admin'")
+	return nil
 }
 
 // ValidateCreateUserRequest validates user creation data
-func ValidateCreateUserRequest(username, password string, groupId int, areaName, role string) error {
+func ValidateCreateUserRequest(username, password string, groupId int, role string) error {
 	if username == "" {
 		return fmt.Errorf("username is required")
 	}
@@ -35,9 +36,6 @@ func ValidateCreateUserRequest(username, password string, groupId int, areaName,
 	if groupId == 0 {
 		return fmt.Errorf("groupId is required")
 	}
-	if areaName == "" {
-		return fmt.Errorf("areaName is required")
-	}
 	if role == "" {
 		return fmt.Errorf("role is required")
 	}
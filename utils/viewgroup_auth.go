@@ -3,49 +3,65 @@ package utils
 import (
 	"fmt"
 	"go-auth/models"
+	"go-auth/policy"
 )
 
 
 func CanCreateViewGroup(user *models.User, targetGroupId int) error {
-	// Basic Users cannot create view groups
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic users cannot create view groups")
+	// Sessions limited to specific view groups cannot add new ones
+	if user.Auth != nil && len(user.Auth.ViewGroupIDs) > 0 {
+		return fmt.Errorf("this session is limited to specific view groups")
 	}
 
-	// Area Admin can only create for their area
-	if user.Role == "Area Admin" && user.GroupId != targetGroupId {
-		return fmt.Errorf("area admin can only create view groups for their own area")
-	}
-
-	return nil
+	return policy.Authorize(user, policy.ViewGroupCreate, policy.InArea(targetGroupId))
 }
 
 //  update view groups
 func CanUpdateViewGroup(user *models.User, viewGroup *models.ViewGroup) error {
-	// Basic Users cannot update view groups
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic users cannot update view groups")
-	}
-
-	// Area Admin can only update their area's view groups
-	if user.Role == "Area Admin" && user.GroupId != viewGroup.GroupID {
-		return fmt.Errorf("area admin can only update view groups in their own area")
+	if err := checkViewGroupRestriction(user, viewGroup); err != nil {
+		return err
 	}
 
-	return nil
+	return policy.Authorize(user, policy.ViewGroupUpdate, policy.ViewGroup(viewGroup))
 }
 
 //  delete view groups
 func CanDeleteViewGroup(user *models.User, viewGroup *models.ViewGroup) error {
-	// Basic Users cannot delete view groups
-	if user.Role == "Basic User" {
-		return fmt.Errorf("basic Users cannot delete view groups")
+	if err := checkViewGroupRestriction(user, viewGroup); err != nil {
+		return err
 	}
 
-	// Area Admin can only delete their area's view groups
-	if user.Role == "Area Admin" && user.GroupId != viewGroup.GroupID {
-		return fmt.Errorf("area admin can only delete view groups in their own area")
+	return policy.Authorize(user, policy.ViewGroupDelete, policy.ViewGroup(viewGroup))
+}
+
+// CanViewViewGroup checks if user can see a view group
+func CanViewViewGroup(user *models.User, viewGroup *models.ViewGroup) error {
+	if err := checkViewGroupRestriction(user, viewGroup); err != nil {
+		return err
 	}
 
-	return nil
-}
\ No newline at end of file
+	return policy.Authorize(user, policy.ViewGroupView, policy.ViewGroup(viewGroup))
+}
+
+// CanShareViewGroup checks if user can manage a view group's grants. Only
+// users whose role can update it may share it; a grant cannot be re-shared.
+func CanShareViewGroup(user *models.User, viewGroup *models.ViewGroup) error {
+	if err := checkViewGroupRestriction(user, viewGroup); err != nil {
+		return err
+	}
+
+	return policy.Authorize(user, policy.ViewGroupUpdate, policy.InArea(viewGroup.GroupID))
+}
+
+// checkViewGroupRestriction enforces the view group list of a restricted session
+func checkViewGroupRestriction(user *models.User, viewGroup *models.ViewGroup) error {
+	if user.Auth == nil || len(user.Auth.ViewGroupIDs) == 0 {
+		return nil
+	}
+	for _, id := range user.Auth.ViewGroupIDs {
+		if id == viewGroup.ID {
+			return nil
+		}
+	}
+	return fmt.Errorf("this session is limited to specific view groups")
+}
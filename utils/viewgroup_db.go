@@ -3,6 +3,7 @@ package utils
 import (
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 )
 
 // GetViewGroupByID retrieves a view group by ID
@@ -30,8 +31,11 @@ func UpdateViewGroupInDB(id string, updateData map[string]interface{}) error {
 	return db.DB.Model(&models.ViewGroup{}).Where("id = ?", id).Updates(updateData).Error
 }
 
-// DeleteViewGroupFromDB deletes a view group from database
+// DeleteViewGroupFromDB deletes a view group and its grants from database
 func DeleteViewGroupFromDB(id string) error {
+	if err := DeleteResourceGrants(policy.ResourceViewGroup, id); err != nil {
+		return err
+	}
 	return db.DB.Where("id = ?", id).Delete(&models.ViewGroup{}).Error
 }
 
@@ -40,9 +44,20 @@ func GetViewGroupsByUser(user *models.User) ([]models.ViewGroup, error) {
 	var viewGroups []models.ViewGroup
 	query := db.DB.Order("created_at DESC")
 
-	if user.Role != "admin" {
-		// Area Admin and Basic User get only their area's view groups
-		query = query.Where("group_id = ?", user.GroupId)
+	// Users without an all-areas grant only get view groups in their areas
+	// and those shared with them
+	areas := policy.Areas(user, policy.ViewGroupView)
+	if !areas.All {
+		shared := policy.GrantedResourceIDs(user, policy.ResourceViewGroup, policy.ViewGroupView)
+		if areas.None() && len(shared) == 0 {
+			return viewGroups, nil
+		}
+		query = query.Where(db.DB.Where("group_id IN ?", areas.GroupIDs).Or("id IN ?", shared))
+	}
+
+	// Sessions from a restricted login link only see the listed view groups
+	if user.Auth != nil && len(user.Auth.ViewGroupIDs) > 0 {
+		query = query.Where("id IN ?", user.Auth.ViewGroupIDs)
 	}
 
 	if err := query.Find(&viewGroups).Error; err != nil {
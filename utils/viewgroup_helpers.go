@@ -9,10 +9,19 @@ import (
 
 	"go-auth/db"
 	"go-auth/models"
+	"go-auth/policy"
 )
 
-//  user from session cookie
+//  user from session cookie or API key
 func GetUserFromSession(r *http.Request) (*models.User, error) {
+	// Machine clients authenticate with an API key instead of a cookie
+	if bearer := GetBearerToken(r); bearer != "" {
+		if IsDeviceCredential(bearer) {
+			return nil, ErrDeviceCredential
+		}
+		return GetUserFromAPIKey(r, bearer)
+	}
+
 	// Get session cookie
 	cookie, err := GetSessionCookie(r)
 	if err != nil {
@@ -20,23 +29,55 @@ func GetUserFromSession(r *http.Request) (*models.User, error) {
 	}
 
 	// Verify session JWT
-	payload, err := VerifySessionJWT(cookie.Value)
+	_, session, err := VerifySessionToken(cookie.Value)
 	if err != nil {
 		return nil, fmt.Errorf("invalid session token")
 	}
 
-	// Extract username from payload
-	username, ok := payload["username"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid session payload")
+	// Cookies are sent automatically, so mutating requests must prove they came from our frontend
+	if IsMutatingRequest(r) {
+		if err := CheckCSRFToken(r, session); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sessions started from a read-only login link cannot change anything
+	if session.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodOptions {
+		return nil, ErrReadOnlySession
+	}
+
+	// Impersonation sessions are audited and limited
+	if err := CheckImpersonation(r, session); err != nil {
+		return nil, err
 	}
 
-	// Get user from database
+	// Get the session's user from database
 	var user models.User
-	if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := db.DB.First(&user, session.UserID).Error; err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
 
+	// Users on an access schedule lose their session outside its hours. An
+	// impersonating admin is not held to the target's schedule.
+	if session.ImpersonatorID == nil {
+		if err := policy.CheckSchedule(&user, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	TouchSession(session)
+
+	user.Auth = &models.AuthContext{
+		Method:       "session",
+		SessionID:    session.ID,
+		ReadOnly:     session.ReadOnly,
+		ViewGroupIDs: session.ViewGroupIDs,
+		LoginTokenID: session.LoginTokenID,
+
+		ImpersonatorID:   session.ImpersonatorID,
+		ImpersonatorName: session.ImpersonatorName,
+	}
+
 	return &user, nil
 }
 
@@ -39,8 +39,8 @@ func ValidateCreateRequest(r *http.Request) (*CreateViewGroupRequest, error) {
 	}
 
 	// Validate required fields
-	if req.ID == "" || req.Name == "" || req.GroupID == 0 || req.AreaName == "" {
-		return nil, fmt.Errorf("ID, name, groupId, and areaName are required")
+	if req.ID == "" || req.Name == "" || req.GroupID == 0 {
+		return nil, fmt.Errorf("ID, name and groupId are required")
 	}
 
 	// Initialize cameras array if nil